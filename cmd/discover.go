@@ -0,0 +1,84 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/rodneyxr/ffatoolkit/ffa"
+	"github.com/spf13/cobra"
+	"log"
+	"strings"
+	"time"
+)
+
+var discoverOpts ffa.DiscoverOptions
+var pushedAfterFlag string
+var outputFlag string
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover query...",
+	Short: "Searches GitHub for repos and adds them to the repos file",
+	Example: `  ffatoolkit discover language:go stars:>500
+  ffatoolkit discover --code filename:Dockerfile language:go --min-stars 100`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if pushedAfterFlag != "" {
+			pushedAfter, err := time.Parse("2006-01-02", pushedAfterFlag)
+			if err != nil {
+				log.Fatalf("invalid --pushed-after date %q: expected YYYY-MM-DD", pushedAfterFlag)
+			}
+			discoverOpts.PushedAfter = pushedAfter
+		}
+
+		ctx := context.Background()
+		client := ffa.CreateClient(ctx, githubToken(0, discoverOpts.Code))
+		query := strings.Join(args, " ")
+		urls, err := ffa.DiscoverRepos(ctx, client, query, discoverOpts)
+		if err != nil {
+			// Keep the repos found before the error
+			log.Println(err)
+		}
+
+		filename := reposFile
+		if outputFlag != "" {
+			filename = outputFlag
+		}
+		added, appendErr := ffa.AppendRepoList(filename, urls)
+		if appendErr != nil {
+			log.Fatal(appendErr)
+		}
+		for _, url := range added {
+			fmt.Println(url)
+		}
+		fmt.Printf("Found %d repos, added %d to %s\n", len(urls), len(added), filename)
+		if err != nil {
+			log.Fatal("discover stopped early")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+	discoverCmd.Flags().BoolVar(&discoverOpts.Code, "code", false, "run a code search (ex: filename:Dockerfile) instead of a repository search")
+	discoverCmd.Flags().IntVar(&discoverOpts.MinStars, "min-stars", 0, "leave out repos with fewer stars")
+	discoverCmd.Flags().StringVar(&pushedAfterFlag, "pushed-after", "", "leave out repos last pushed before this date (YYYY-MM-DD)")
+	discoverCmd.Flags().BoolVar(&discoverOpts.IncludeArchived, "include-archived", false, "include archived repos")
+	discoverCmd.Flags().BoolVar(&discoverOpts.IncludeForks, "include-forks", false, "include forks")
+	discoverCmd.Flags().IntVar(&discoverOpts.Limit, "limit", 100, "number of repos to find at most (0 for as many as the search returns)")
+	discoverCmd.Flags().IntVar(&discoverOpts.PerPage, "per-page", 100, "number of search results to request at a time (max 100)")
+	discoverCmd.Flags().StringVar(&outputFlag, "output", "", "file to add the repos to instead of the repos file")
+}
@@ -24,7 +24,6 @@ import (
 
 var repoURL string
 var repoLanguage string
-var gitToken string
 
 // infoCmd represents the list command
 var infoCmd = &cobra.Command{
@@ -37,9 +36,17 @@ var infoCmd = &cobra.Command{
 		// if repoURL was provided load the repo
 		if repoURL != "" {
 			ctx := context.Background()
-			client := ffa.CreateClient(ctx, gitToken)
 			log.Println("Fetching repo info:", repoURL)
-			repo := ffa.NewRepo(ctx, client, repoURL)
+			var repo ffa.Repo
+			if ffa.IsLocalSource(repoURL) {
+				repo, err = ffa.NewLocalRepo(ctx, repoURL, nil)
+			} else {
+				client := ffa.CreateClient(ctx, githubToken(0, true))
+				repo, err = ffa.NewRepo(ctx, client, repoURL, ffa.FetchOptions{PerPage: 100})
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
 			repoList = append(repoList, repo)
 		} else {
 			// Load repos from the cache
@@ -76,16 +83,18 @@ var infoCmd = &cobra.Command{
 		for i, repo := range filteredRepos {
 			fmt.Printf("%d: %s\n", i, repo.URL)
 
-			// For each first Dockerfile in each repo
-			if len(repo.Dockerfiles) > 0 {
+			// For each Dockerfile in each repo
+			for j, dockerfile := range repo.Dockerfiles {
 				// Parse the Dockerfile
-				ffa, err := ffa.TranslateDockerfile(repo.Dockerfiles[0])
+				name := fileEntryName(dockerfile, "Dockerfile", j)
+				ffa, err := ffa.TranslateDockerfile(dockerfile.Content, ffa.DockerfileOptions{})
 				if err != nil {
-					log.Print(err)
+					log.Printf("%s: %v", name, err)
 				} else {
-					log.Println(ffa)
+					log.Println(name, ffa)
 				}
-			} else {
+			}
+			if len(repo.Dockerfiles) == 0 {
 				log.Println("No Dockerfile found.")
 			}
 		}
@@ -94,7 +103,6 @@ var infoCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(infoCmd)
-	infoCmd.Flags().StringVar(&repoURL, "repo", "", "Git repo URL")
+	infoCmd.Flags().StringVar(&repoURL, "repo", "", "Git repo URL, local directory or file:// git URL")
 	infoCmd.Flags().StringVar(&repoLanguage, "filter-lang", "", "Repo language to filter")
-	infoCmd.Flags().StringVar(&gitToken, "token", "", "GitHub access token")
 }
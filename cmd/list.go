@@ -16,7 +16,6 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/asottile/dockerfile"
 	"github.com/rodneyxr/ffatoolkit/ffa"
 	"github.com/spf13/cobra"
 	"io/ioutil"
@@ -63,36 +62,45 @@ var listCmd = &cobra.Command{
 		for i, repo := range goRepos {
 			fmt.Printf("%d: %s\n", i, repo.URL)
 
-			// For each first Dockerfile in each repo
-			if len(repo.Dockerfiles) > 0 {
-				// Save the dockerfile to a file
+			// For each Dockerfile in each repo
+			for j, dockerfile := range repo.Dockerfiles {
+				name := fileEntryName(dockerfile, "Dockerfile", j)
+				fmt.Printf("  %s\n", name)
+
+				// Save the dockerfile to a file named after its path
 				if saveFlag {
-					dockerFilename := strings.Join([]string{repo.Owner, repo.Repo, "Dockerfile"}, "_")
-					if err := ioutil.WriteFile(filepath.Join("dockerfiles", dockerFilename), []byte(repo.Dockerfiles[0]), os.ModePerm); err != nil {
+					dockerFilename := strings.Join([]string{repo.Owner, repo.Repo, strings.ReplaceAll(name, "/", "_")}, "_")
+					if err := ioutil.WriteFile(filepath.Join("dockerfiles", dockerFilename), []byte(dockerfile.Content), os.ModePerm); err != nil {
 						log.Fatal(err)
 					}
 				}
 
 				// Parse the Dockerfile
-				reader := strings.NewReader(repo.Dockerfiles[0])
-				commandList, err := dockerfile.ParseReader(reader)
+				runCommandList, err := ffa.ExtractRunCommandsFromDockerfile(dockerfile.Content)
 				if err != nil {
-					log.Print(err)
+					log.Printf("%s: %v", name, err)
 					continue
 				}
 
-				// Print all commands in the Dockerfile
-				for _, cmd := range commandList {
-					if cmd.Cmd == "run" {
-						fmt.Println(cmd.Cmd, cmd.Value)
-					}
+				// Print all RUN commands in the Dockerfile
+				for _, cmd := range runCommandList {
+					fmt.Println(cmd.Cmd, cmd.Value)
 				}
-
 			}
 		}
 	},
 }
 
+// fileEntryName returns the name a file is reported and saved under, which is
+// its path in the repo. Files cached without their path are numbered after
+// the prefix instead (ex: Dockerfile-0).
+func fileEntryName(entry ffa.FileEntry, prefix string, index int) string {
+	if entry.Path != "" {
+		return entry.Path
+	}
+	return fmt.Sprintf("%s-%d", prefix, index)
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
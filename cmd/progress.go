@@ -0,0 +1,54 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// progress reports how many of a number of tasks have finished, along with
+// an estimate of the remaining time.
+type progress struct {
+	mu     sync.Mutex
+	total  int
+	done   int
+	failed int
+	start  time.Time
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, start: time.Now()}
+}
+
+// finish records a finished task and reports the progress.
+func (p *progress) finish(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if err != nil {
+		p.failed++
+		log.Printf("%s: %v", name, err)
+	}
+
+	elapsed := time.Since(p.start)
+	remaining := time.Duration(0)
+	if p.done < p.total {
+		remaining = elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+	}
+	log.Printf("[%d/%d %3d%%] %s (%d failed, %s remaining)", p.done, p.total, p.done*100/p.total, name,
+		p.failed, remaining.Round(time.Second))
+}
@@ -54,11 +54,11 @@ var rankCmd = &cobra.Command{
 
 			// For each Dockerfile in each repo
 			// For each first Dockerfile in each repo
-			for _, dockerfile := range repo.Dockerfiles {
+			for j, dockerfile := range repo.Dockerfiles {
 				//if len(repo.Dockerfiles) > 0 {
-				runCommandList, err := ffa.ExtractRunCommandsFromDockerfile(dockerfile)
+				runCommandList, err := ffa.ExtractRunCommandsFromDockerfile(dockerfile.Content)
 				if err != nil {
-					log.Print(err)
+					log.Printf("%s: %v", fileEntryName(dockerfile, "Dockerfile", j), err)
 					continue
 				}
 
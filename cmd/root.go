@@ -16,13 +16,17 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/rodneyxr/ffatoolkit/ffa"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"log"
 	"os"
 )
 
 var reposFile string
 var cacheFile string
+var tokenFlag string
+var tokenFileFlag string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -44,6 +48,31 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&reposFile, "repos", "repos.yaml", "list of repos to update")
 	rootCmd.PersistentFlags().StringVar(&cacheFile, "resultsfile", "results.json", "output file as json")
+	rootCmd.PersistentFlags().StringVar(&tokenFlag, "token", "", "GitHub access token (default from --token-file, GITHUB_TOKEN, GH_TOKEN or the gh CLI)")
+	rootCmd.PersistentFlags().StringVar(&tokenFileFlag, "token-file", "", "file containing GitHub access token")
+}
+
+// githubToken returns the GitHub token to use for a command making about the
+// given number of requests. Commands using code search, which requires a
+// token, or making more requests than allowed without one exit when there is
+// none. The token itself is never printed.
+func githubToken(requests int, codeSearch bool) string {
+	token, source, err := ffa.ResolveToken(tokenFlag, tokenFileFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case token != "":
+		log.Println("Using GitHub token from", source)
+	case codeSearch:
+		log.Fatalf("no GitHub token found, which code search requires: %s", ffa.TokenHelp)
+	case requests > ffa.UnauthenticatedRateLimit:
+		log.Fatalf("no GitHub token found, and about %d requests are needed but only %d an hour are allowed without one: %s",
+			requests, ffa.UnauthenticatedRateLimit, ffa.TokenHelp)
+	default:
+		log.Printf("No GitHub token found, requests are limited to %d an hour", ffa.UnauthenticatedRateLimit)
+	}
+	return token
 }
 
 // initConfig reads in the list of repos defined in a yaml file.
@@ -27,62 +27,116 @@ import (
 var fileTypeFlag string
 var filepathFlag string
 var resultsDir string
+var splitStagesFlag bool
+var buildArgsFlag []string
+var recordUserFlag bool
+var profilesFlag []string
+var noProfilesFlag bool
+var contextFlag string
+var fromCacheFlag bool
+
+// translateSource is a file to translate along with the name its results
+// are saved under and the build context of the repo it comes from, if any.
+type translateSource struct {
+	name    string
+	data    string
+	context *ffa.BuildContext
+}
 
 // translateCmd represents the list command
 var translateCmd = &cobra.Command{
 	Use:   "translate",
 	Short: "Translate scripts to FFAL",
 	Run: func(cmd *cobra.Command, args []string) {
-		var files []string
-
-		// Stat the file
-		info, err := os.Stat(filepathFlag)
-		if err != nil {
-			cmd.PrintErrln("could not read " + filepathFlag)
+		var sources []translateSource
+		switch {
+		case fromCacheFlag:
+			if contextFlag != "" {
+				cmd.PrintErrln("--context cannot be used with --from-cache, the context of each repo is read from the cache")
+				os.Exit(1)
+			}
+			sources = cachedSources()
+		case filepathFlag != "":
+			sources = fileSources(cmd)
+		default:
+			cmd.PrintErrln("either --filepath or --from-cache is required")
 			os.Exit(1)
 		}
 
-		if info.IsDir() {
-			// If the file is a directory, add all files to the files list
-			if err := filepath.Walk(filepathFlag, func(path string, info os.FileInfo, err error) error {
-				if !info.IsDir() {
-					files = append(files, path)
-				}
-				return err
-			}); err != nil {
-				panic(err)
+		// Collect the build arguments given as KEY=VALUE or KEY to use the environment
+		dockerOpts := ffa.DockerfileOptions{
+			BuildArgs:  make(map[string]string),
+			RecordUser: recordUserFlag,
+		}
+		for _, buildArg := range buildArgsFlag {
+			if eq := strings.Index(buildArg, "="); eq >= 0 {
+				dockerOpts.BuildArgs[buildArg[:eq]] = buildArg[eq+1:]
+			} else if value, ok := os.LookupEnv(buildArg); ok {
+				dockerOpts.BuildArgs[buildArg] = value
 			}
-		} else {
-			// if it is not a directory, the file will be the only one in the list
-			abs, _ := filepath.Abs(filepathFlag)
-			files = append(files, abs)
 		}
 
-		// Create the results directory
-		_ = os.Mkdir(resultsDir, os.ModeDir)
+		// Load the base image profiles, user profiles take precedence over the
+		// bundled ones
+		var err error
+		if !noProfilesFlag {
+			dockerOpts.Profiles, err = ffa.NewProfileRegistry()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, profilesFile := range profilesFlag {
+				if err := dockerOpts.Profiles.LoadFile(profilesFile); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
 
-		for _, filename := range files {
-			// Read the file data
-			data, err := ioutil.ReadFile(filename)
+		// Read the build context the Dockerfile sources are copied from, cached
+		// repos have their own
+		if contextFlag != "" {
+			dockerOpts.Context, err = ffa.LoadBuildContext(contextFlag)
 			if err != nil {
-				log.Println(err)
-				continue
+				log.Fatal(err)
 			}
+		}
 
+		// Create the results directory
+		_ = os.Mkdir(resultsDir, os.ModeDir)
+
+		for _, source := range sources {
 			var ffaScript []string
 			switch fileTypeFlag {
 			case "docker":
-				ffaScript, err = ffa.TranslateDockerfile(string(data))
+				if fromCacheFlag {
+					dockerOpts.Context = source.context
+				}
+				if splitStagesFlag {
+					// Save each stage to its own file
+					stages, err := ffa.TranslateDockerfileStages(source.data, dockerOpts)
+					if err != nil {
+						log.Println(err)
+						continue
+					}
+					for _, stage := range stages {
+						ffaFilename := filepath.Join(resultsDir, source.name+"."+stage.Name+".ffa")
+						ffaScriptData := []byte(strings.Join(stage.Script, "\n"))
+						if err = ioutil.WriteFile(ffaFilename, ffaScriptData, os.ModePerm); err != nil {
+							log.Print(err)
+						}
+					}
+					continue
+				}
+				ffaScript, err = ffa.TranslateDockerfile(source.data, dockerOpts)
 				if err != nil {
 					log.Println(err)
 					continue
 				}
 				break
 			case "shell":
-				ffaScript, err = ffa.TranslateShellScript(string(data))
+				ffaScript, err = ffa.TranslateShellScript(source.data)
 				if err != nil {
 					// skip this file to avoid a partially translated file
-					log.Printf("failed to parse %s: %s", filename, err)
+					log.Printf("failed to parse %s: %s", source.name, err)
 					continue
 				}
 				//ffaScript = append(ffaScript, results...)
@@ -92,7 +146,7 @@ var translateCmd = &cobra.Command{
 			}
 
 			// Save the ffa script to a file
-			ffaFilename := filepath.Join(resultsDir, filepath.Base(filename)+".ffa")
+			ffaFilename := filepath.Join(resultsDir, source.name+".ffa")
 			ffaScriptData := []byte(strings.Join(ffaScript, "\n"))
 			if err = ioutil.WriteFile(ffaFilename, ffaScriptData, os.ModePerm); err != nil {
 				log.Print(err)
@@ -102,10 +156,94 @@ var translateCmd = &cobra.Command{
 	},
 }
 
+// fileSources reads the file or the files of the directory to translate.
+func fileSources(cmd *cobra.Command) []translateSource {
+	var files []string
+
+	// Stat the file
+	info, err := os.Stat(filepathFlag)
+	if err != nil {
+		cmd.PrintErrln("could not read " + filepathFlag)
+		os.Exit(1)
+	}
+
+	if info.IsDir() {
+		// If the file is a directory, add all files to the files list
+		if err := filepath.Walk(filepathFlag, func(path string, info os.FileInfo, err error) error {
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return err
+		}); err != nil {
+			panic(err)
+		}
+	} else {
+		// if it is not a directory, the file will be the only one in the list
+		abs, _ := filepath.Abs(filepathFlag)
+		files = append(files, abs)
+	}
+
+	var sources []translateSource
+	for _, filename := range files {
+		// Read the file data
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		sources = append(sources, translateSource{name: filepath.Base(filename), data: string(data)})
+	}
+	return sources
+}
+
+// cachedSources returns the files of the type being translated from each
+// repo in the cache, named after the repo and their path.
+func cachedSources() []translateSource {
+	repoList, err := ffa.LoadRepoCache(cacheFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sources []translateSource
+	for _, repo := range repoList {
+		entries, prefix := repo.Scripts, "script"
+		var context *ffa.BuildContext
+		if fileTypeFlag == "docker" {
+			entries, prefix = repo.Dockerfiles, "Dockerfile"
+
+			// Dockerfiles are built from the root of their repo
+			context, err = repo.BuildContext()
+			if err != nil {
+				log.Printf("%s/%s: %v", repo.Owner, repo.Repo, err)
+			} else if context == nil && len(entries) > 0 {
+				log.Printf("%s/%s: no build context cached (collect it again with update)", repo.Owner, repo.Repo)
+			}
+		}
+		for i, entry := range entries {
+			name := strings.ReplaceAll(fileEntryName(entry, prefix, i), "/", "_")
+			sources = append(sources, translateSource{
+				name:    strings.Join([]string{repo.Owner, repo.Repo, name}, "_"),
+				data:    entry.Content,
+				context: context,
+			})
+		}
+	}
+	if len(sources) == 0 {
+		log.Printf("no files to translate in %s (collect them with update --kinds)", cacheFile)
+	}
+	return sources
+}
+
 func init() {
 	rootCmd.AddCommand(translateCmd)
 	translateCmd.Flags().StringVar(&fileTypeFlag, "type", "shell", "type of file to analyze (shell or docker)")
 	translateCmd.Flags().StringVar(&filepathFlag, "filepath", "", "path to file or directory to analyze")
+	translateCmd.Flags().BoolVar(&fromCacheFlag, "from-cache", false, "translate the files of each repo in the results file instead of --filepath")
 	translateCmd.Flags().StringVar(&resultsDir, "results", "results", "directory to save results")
-	_ = translateCmd.MarkFlagRequired("filepath")
+	translateCmd.Flags().StringArrayVar(&buildArgsFlag, "build-arg", nil, "set a Dockerfile build argument (KEY=VALUE)")
+	translateCmd.Flags().BoolVar(&recordUserFlag, "record-user", false, "record Dockerfile USER switches as diagnostics")
+	translateCmd.Flags().StringArrayVar(&profilesFlag, "profiles", nil, "load additional base image profiles from a YAML file")
+	translateCmd.Flags().BoolVar(&noProfilesFlag, "no-profiles", false, "do not model the filesystem of base images")
+	translateCmd.Flags().StringVar(&contextFlag, "context", "", "Docker build context directory, filtered by its .dockerignore file")
+	translateCmd.Flags().BoolVar(&splitStagesFlag, "split-stages", false, "save each Dockerfile stage to its own <file>.<stage>.ffa file")
 }
@@ -22,11 +22,22 @@ import (
 	"github.com/spf13/viper"
 	"io/ioutil"
 	"log"
+	"strings"
+	"sync"
+	"time"
 )
 
+var perPageFlag int
+var concurrencyFlag int
+var fetchModeFlag string
+var sourceFlag string
+var refreshFlag bool
+var maxAgeFlag time.Duration
+var kindsFlag []string
+
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-	Use:   "update",
+	Use:   "update [repo...]",
 	Short: "Updates/downloads the repo cache",
 	Run: func(cmd *cobra.Command, args []string) {
 		// Load the existing cache
@@ -41,35 +52,102 @@ var updateCmd = &cobra.Command{
 		}
 
 		repoURLs := viper.GetStringSlice("repos")
-		ctx := context.Background()
-		if err != nil {
-			log.Fatal(err)
+		if len(args) > 0 {
+			repoURLs = args
 		}
-		client := ffa.CreateClient(ctx, gitToken)
+		ctx := context.Background()
 
-		// Create the info list to hold all the results
-		var results []ffa.Repo
+		// Fetch the repos missing from the cache in parallel, along with the
+		// cached repos to refresh or missing a kind of file. The workers share
+		// the client and the rate limit of the GitHub API.
+		var pending []string
+		var cached []*ffa.Repo
+		queued := make(map[string]bool)
+		for _, repoURL := range repoURLs {
+			repo, ok := repoMap[repoURL]
+			if queued[repoURL] || (ok && !refreshFlag && !isStale(repo) && repo.HasKinds(kindsFlag)) {
+				continue
+			}
+			queued[repoURL] = true
+			pending = append(pending, repoURL)
+			if ok {
+				cached = append(cached, &repo)
+			} else {
+				cached = append(cached, nil)
+			}
+		}
+		for _, kind := range kindsFlag {
+			if !ffa.IsFileKind(kind) {
+				log.Fatalf("unknown file kind %q (expected one of %s)", kind, strings.Join(ffa.FileKinds, ", "))
+			}
+		}
+		var updateRepo func(repoURL string, cachedRepo *ffa.Repo) (ffa.Repo, error)
+		switch sourceFlag {
+		case "github":
+			fetchOpts := ffa.FetchOptions{Mode: fetchModeFlag, PerPage: perPageFlag, Kinds: kindsFlag}
+			if fetchModeFlag != ffa.FetchModeTree && fetchModeFlag != ffa.FetchModeSearch {
+				log.Fatalf("unknown fetch mode %q", fetchModeFlag)
+			}
+			// Each repo takes at least a request for its commit, its languages,
+			// its files and one of them
+			codeSearch := fetchModeFlag == ffa.FetchModeSearch && len(pending) > 0
+			client := ffa.CreateClient(ctx, githubToken(len(pending)*4, codeSearch))
+			updateRepo = func(repoURL string, cachedRepo *ffa.Repo) (ffa.Repo, error) {
+				if cachedRepo == nil {
+					return ffa.NewRepo(ctx, client, repoURL, fetchOpts)
+				}
+				// Only the changes since the repo was cached are fetched
+				repo := *cachedRepo
+				err := ffa.RefreshRepo(ctx, client, &repo, fetchOpts)
+				return repo, err
+			}
+		case "local":
+			updateRepo = func(repoURL string, cachedRepo *ffa.Repo) (ffa.Repo, error) {
+				return ffa.NewLocalRepo(ctx, repoURL, kindsFlag)
+			}
+		default:
+			log.Fatalf("unknown source %q", sourceFlag)
+		}
+		if concurrencyFlag < 1 {
+			concurrencyFlag = 1
+		}
+		fetched := make([]*ffa.Repo, len(pending))
+		status := newProgress(len(pending))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < concurrencyFlag; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					repo, err := updateRepo(pending[i], cached[i])
+					if err == nil {
+						fetched[i] = &repo
+					}
+					status.finish(pending[i], err)
+				}
+			}()
+		}
+		for i := range pending {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
 
-		for i, repoURL := range repoURLs {
-			// Display progress to the user
-			log.Printf("(%d/%d) %s\n", i+1, len(repoURLs), repoURL)
+		// Repos that failed to be fetched keep their cached version, if any,
+		// or are left out of the cache so they are fetched again
+		for i, repo := range fetched {
+			if repo != nil {
+				repoMap[pending[i]] = *repo
+			}
+		}
 
-			// Check if the repo exists already
-			repo, ok := repoMap[repoURL]
-			if !ok {
-				// Create and add the repo object to the result set
-				repo = ffa.NewRepo(ctx, client, repoURL)
-				repoMap[repoURL] = repo
+		// Keep the order of the repos file
+		var results []ffa.Repo
+		for _, repoURL := range repoURLs {
+			if repo, ok := repoMap[repoURL]; ok {
+				results = append(results, repo)
 			}
-			//if repo.Languages == nil {
-			//	if err := ffa.LoadLanguages(ctx, client, &repo); err != nil {
-			//		log.Println(err)
-			//	}
-			//}
-			//if repo.Dockerfiles == nil {
-			//	ffa.LoadDockerfiles(ctx, client, &repo)
-			//}
-			results = append(results, repo)
 		}
 
 		// Write the results to a cache file
@@ -80,7 +158,18 @@ var updateCmd = &cobra.Command{
 	},
 }
 
+// isStale reports whether a cached repo is older than the maximum age.
+func isStale(repo ffa.Repo) bool {
+	return maxAgeFlag > 0 && time.Since(repo.FetchedAt) > maxAgeFlag
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
-	updateCmd.Flags().StringVar(&gitToken, "token", "", "GitHub access token")
+	updateCmd.Flags().IntVar(&concurrencyFlag, "concurrency", 4, "number of repos to fetch at the same time")
+	updateCmd.Flags().BoolVar(&refreshFlag, "refresh", false, "refresh every cached repo, downloading only what changed")
+	updateCmd.Flags().DurationVar(&maxAgeFlag, "max-age", 0, "refresh cached repos fetched longer ago than this (ex: 720h)")
+	updateCmd.Flags().StringVar(&sourceFlag, "source", "github", "where to fetch repos from: GitHub or local directories and file:// git URLs (github or local)")
+	updateCmd.Flags().StringVar(&fetchModeFlag, "fetch-mode", ffa.FetchModeSearch, "how to find files: search code or list the repo tree (search or tree)")
+	updateCmd.Flags().StringSliceVar(&kindsFlag, "kinds", []string{ffa.FileKindDockerfile}, "kinds of files to collect (dockerfile, shell or makefile)")
+	updateCmd.Flags().IntVar(&perPageFlag, "per-page", 100, "number of code search results to request at a time (max 100)")
 }
@@ -0,0 +1,290 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Kinds of files collected from repositories.
+const (
+	FileKindDockerfile = "dockerfile"
+	FileKindShell      = "shell"
+	FileKindMakefile   = "makefile"
+)
+
+// FileKinds are the kinds of files that can be collected.
+var FileKinds = []string{FileKindDockerfile, FileKindShell, FileKindMakefile}
+
+// fileKind describes how the files of a kind are found and where they are
+// stored in a repo.
+type fileKind struct {
+	// match reports whether a file name is the name of a file of the kind.
+	match func(name string) bool
+
+	// shebang reports whether files without an extension are of the kind
+	// when they start with a shell shebang. Only executable files are
+	// checked in trees, and search results are never checked.
+	shebang bool
+
+	// queries find the files of the kind with code search, whose results
+	// are kept if keep reports true for their path.
+	queries []string
+	keep    func(path string) bool
+
+	files func(repoInfo *Repo) *[]FileEntry
+}
+
+var fileKinds = map[string]fileKind{
+	FileKindDockerfile: {
+		match:   isDockerfileName,
+		queries: []string{"dockerfile+in:path"},
+		// The search also matches source files such as dockerfile.go
+		keep:  func(p string) bool { return !strings.HasSuffix(p, ".go") },
+		files: func(repoInfo *Repo) *[]FileEntry { return &repoInfo.Dockerfiles },
+	},
+	FileKindShell: {
+		match:   isShellScriptName,
+		shebang: true,
+		queries: []string{"extension:sh", "extension:bash"},
+		keep:    func(p string) bool { return isShellScriptName(path.Base(p)) },
+		files:   func(repoInfo *Repo) *[]FileEntry { return &repoInfo.Scripts },
+	},
+	FileKindMakefile: {
+		match:   isMakefileName,
+		queries: []string{"filename:Makefile", "filename:GNUmakefile", "extension:mk"},
+		keep:    func(p string) bool { return isMakefileName(path.Base(p)) },
+		files:   func(repoInfo *Repo) *[]FileEntry { return &repoInfo.Makefiles },
+	},
+}
+
+// IsFileKind reports whether a name is one of the FileKinds.
+func IsFileKind(name string) bool {
+	_, ok := fileKinds[name]
+	return ok
+}
+
+// fileKindsOf returns the kinds of files to collect, which are Dockerfiles
+// unless other kinds are given.
+func fileKindsOf(kinds []string) ([]string, error) {
+	if len(kinds) == 0 {
+		return []string{FileKindDockerfile}, nil
+	}
+	for _, kind := range kinds {
+		if !IsFileKind(kind) {
+			return nil, fmt.Errorf("unknown file kind %q (expected one of %s)", kind, strings.Join(FileKinds, ", "))
+		}
+	}
+	return kinds, nil
+}
+
+// HasKinds reports whether the files of the given kinds were collected.
+func (r *Repo) HasKinds(kinds []string) bool {
+	kinds, err := fileKindsOf(kinds)
+	if err != nil {
+		return false
+	}
+	collected := r.collectedKinds()
+	for _, kind := range kinds {
+		if !containsString(collected, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// collectedKinds returns the kinds of files collected. Repos cached before
+// kinds were recorded only have Dockerfiles.
+func (r *Repo) collectedKinds() []string {
+	if len(r.Kinds) == 0 && len(r.Dockerfiles) > 0 {
+		return []string{FileKindDockerfile}
+	}
+	return r.Kinds
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadFiles collects the files of the requested kinds from a repository,
+// along with the images the Dockerfiles are built from. Files of kinds that
+// are not requested are left as they are.
+func LoadFiles(ctx context.Context, client *github.Client, repoInfo *Repo, opts FetchOptions) error {
+	kinds, err := fileKindsOf(opts.Kinds)
+	if err != nil {
+		return err
+	}
+
+	// The tree is listed once for every kind
+	var tree []repoFile
+	repoInfo.Truncated, repoInfo.TreeTruncated = false, false
+	switch opts.Mode {
+	case FetchModeSearch, "":
+	case FetchModeTree:
+		if tree, err = listFiles(ctx, client, repoInfo); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown fetch mode %q", opts.Mode)
+	}
+
+	collected := repoInfo.collectedKinds()
+	loadImages := false
+	for _, name := range kinds {
+		kind := fileKinds[name]
+		loadImages = loadImages || name == FileKindDockerfile
+
+		// Get the list of files of the kind in the repo. Executables found
+		// without a matching name are only kept if they have a shebang.
+		var files []repoFile
+		shebangOnly := make(map[string]bool)
+		if opts.Mode == FetchModeTree {
+			for _, file := range tree {
+				base := path.Base(file.Path)
+				switch {
+				case kind.match(base):
+					files = append(files, file)
+				case kind.shebang && file.Executable && path.Ext(base) == "":
+					files = append(files, file)
+					shebangOnly[file.Path] = true
+				}
+			}
+		} else {
+			found := make(map[string]bool)
+			for _, query := range kind.queries {
+				results, truncated, err := searchFiles(ctx, client, repoInfo, query, opts)
+				if err != nil {
+					return err
+				}
+				repoInfo.Truncated = repoInfo.Truncated || truncated
+				for _, file := range results {
+					if !found[file.Path] && kind.keep(file.Path) {
+						found[file.Path] = true
+						files = append(files, file)
+					}
+				}
+			}
+		}
+
+		entries := kind.files(repoInfo)
+		if *entries, err = downloadFiles(ctx, client, repoInfo, *entries, files, shebangOnly); err != nil {
+			return err
+		}
+	}
+
+	// Record the build context and search for FROM statements in each
+	// docker file. Code searches only list the tree when the Dockerfiles
+	// copy files from the build context.
+	if loadImages {
+		if opts.Mode != FetchModeTree && usesBuildContext(repoInfo.Dockerfiles) {
+			if tree, err = listFiles(ctx, client, repoInfo); err != nil {
+				return err
+			}
+		}
+		if err := loadContext(ctx, client, repoInfo, tree); err != nil {
+			return err
+		}
+		var images []Image
+		for _, dockerfile := range repoInfo.Dockerfiles {
+			fromImages, err := ExtractImagesFromDockerfile(dockerfile.Content)
+			if err != nil {
+				log.Printf("error parsing %s: %v", dockerfile.Path, err)
+			}
+			images = append(images, fromImages...)
+		}
+		repoInfo.Images = images
+	}
+	for _, kind := range kinds {
+		if !containsString(collected, kind) {
+			collected = append(collected, kind)
+		}
+	}
+	repoInfo.Kinds = collected
+	return nil
+}
+
+// loadContext records the files of a repository and downloads its root
+// .dockerignore file, if any.
+func loadContext(ctx context.Context, client *github.Client, repoInfo *Repo, tree []repoFile) error {
+	repoInfo.Tree = nil
+	var ignore []repoFile
+	for _, file := range tree {
+		repoInfo.Tree = append(repoInfo.Tree, file.Path)
+		if file.Path == ".dockerignore" {
+			ignore = append(ignore, file)
+		}
+	}
+
+	var cached []FileEntry
+	if repoInfo.Dockerignore != nil {
+		cached = append(cached, *repoInfo.Dockerignore)
+	}
+	entries, err := downloadFiles(ctx, client, repoInfo, cached, ignore, nil)
+	if err != nil {
+		return err
+	}
+	repoInfo.Dockerignore = nil
+	if len(entries) > 0 {
+		repoInfo.Dockerignore = &entries[0]
+	}
+	return nil
+}
+
+// downloadFiles returns the entries of the files of a kind. Files whose
+// content did not change since they were cached are not downloaded again.
+// Files in shebangOnly are left out unless they start with a shell shebang.
+func downloadFiles(ctx context.Context, client *github.Client, repoInfo *Repo, cachedFiles []FileEntry, files []repoFile, shebangOnly map[string]bool) ([]FileEntry, error) {
+	cached := make(map[string]FileEntry)
+	for _, entry := range cachedFiles {
+		cached[entry.Path] = entry
+	}
+
+	var entries []FileEntry
+	for _, file := range files {
+		entry, ok := cached[file.Path]
+		if !ok || file.SHA == "" || entry.BlobSHA != file.SHA {
+			var data []byte
+			if err := DefaultRetrier.Do(ctx, func() (resp *github.Response, err error) {
+				data, resp, err = client.Git.GetBlobRaw(ctx, repoInfo.Owner, repoInfo.Repo, file.SHA)
+				return resp, err
+			}); err != nil {
+				return nil, fmt.Errorf("error downloading %s: %v", file.Path, err)
+			}
+			if shebangOnly[file.Path] && !isShellShebang(string(data)) {
+				continue
+			}
+			entry = NewFileEntry(file.Path, file.Commit, string(data))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// isMakefileName reports whether a file name is the name of a makefile
+// (ex: Makefile, GNUmakefile or rules.mk).
+func isMakefileName(name string) bool {
+	return name == "Makefile" || name == "makefile" || name == "GNUmakefile" || path.Ext(name) == ".mk"
+}
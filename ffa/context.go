@@ -0,0 +1,169 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
+	"github.com/moby/patternmatcher"
+)
+
+// BuildContext holds the files of a Docker build context that are sent to
+// the builder, which are those not excluded by the .dockerignore file.
+type BuildContext struct {
+	files []string
+}
+
+// NewBuildContext creates a build context from the paths of the files in the
+// context, relative to its root, and the contents of its .dockerignore file.
+func NewBuildContext(files []string, ignore string) (*BuildContext, error) {
+	patterns, err := dockerignore.ReadAll(strings.NewReader(ignore))
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .dockerignore: %v", err)
+	}
+
+	c := &BuildContext{}
+	for _, file := range files {
+		file = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(file)), "/")
+		if file == "" {
+			continue
+		}
+		// Files are excluded by matching them or one of their parents
+		excluded, err := matcher.MatchesOrParentMatches(filepath.FromSlash(file))
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+		c.files = append(c.files, file)
+	}
+	sort.Strings(c.files)
+	return c, nil
+}
+
+// LoadBuildContext reads the build context of a local directory.
+func LoadBuildContext(dir string) (*BuildContext, error) {
+	var files []string
+	if err := filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(dir, filename)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ignore, err := ioutil.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return NewBuildContext(files, string(ignore))
+}
+
+// usesBuildContext reports whether any of the Dockerfiles copies files with
+// COPY or ADD, which may come from the build context.
+func usesBuildContext(dockerfiles []FileEntry) bool {
+	for _, dockerfile := range dockerfiles {
+		commandList, err := ExtractAllCommandsFromDockerfile(dockerfile.Content)
+		if err != nil {
+			continue
+		}
+		for _, cmd := range commandList {
+			if cmd.Cmd == "copy" || cmd.Cmd == "add" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BuildContext returns the build context of the Dockerfiles of a repo, which
+// is the root of the repo, or nil if its files were not recorded.
+func (r *Repo) BuildContext() (*BuildContext, error) {
+	if len(r.Tree) == 0 {
+		return nil, nil
+	}
+	var ignore string
+	if r.Dockerignore != nil {
+		ignore = r.Dockerignore.Content
+	}
+	return NewBuildContext(r.Tree, ignore)
+}
+
+// Files returns the paths of the files in the context, relative to its root.
+func (c *BuildContext) Files() []string {
+	return c.files
+}
+
+// contextSource is a file of the build context copied by COPY or ADD.
+type contextSource struct {
+	file string // path of the file in the context
+	rel  string // path of the file relative to the directory being copied
+	dir  bool   // whether the file is copied as part of a directory
+}
+
+// sources returns the files of the context a COPY or ADD source refers to.
+// Sources may be wildcards, and the contents of directories are copied
+// recursively.
+func (c *BuildContext) sources(src string) []contextSource {
+	src = strings.TrimPrefix(path.Clean("/"+src), "/")
+	if src == "" {
+		src = "."
+	}
+
+	var sources []contextSource
+	for _, file := range c.files {
+		if src == "." {
+			sources = append(sources, contextSource{file: file, rel: file, dir: true})
+			continue
+		}
+		// Match the file or the first of its parent directories
+		parts := strings.Split(file, "/")
+		for i := 1; i <= len(parts); i++ {
+			prefix := strings.Join(parts[:i], "/")
+			if m, _ := path.Match(src, prefix); m {
+				sources = append(sources, contextSource{
+					file: file,
+					rel:  strings.Join(parts[i:], "/"),
+					dir:  i < len(parts),
+				})
+				break
+			}
+		}
+	}
+	return sources
+}
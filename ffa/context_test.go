@@ -0,0 +1,85 @@
+package ffa
+
+import (
+	"reflect"
+	"testing"
+)
+
+var sampleContextFiles = []string{
+	".dockerignore", ".git/config", "Dockerfile", "README.md", "go.mod", "go.sum",
+	"cmd/app/main.go", "cmd/app/main_test.go", "docs/index.md", "docs/CHANGELOG.md",
+	"vendor/github.com/pkg/errors/errors.go", "build/app",
+}
+
+var sampleDockerignore = `# Ignore everything that is not needed to build the app
+.git
+**/*_test.go
+*.md
+!README.md
+docs
+!docs/CHANGELOG.md
+/build
+`
+
+func TestBuildContextIgnore(t *testing.T) {
+	context, err := NewBuildContext(sampleContextFiles, sampleDockerignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		".dockerignore", "Dockerfile", "README.md", "cmd/app/main.go", "docs/CHANGELOG.md", "go.mod", "go.sum",
+		"vendor/github.com/pkg/errors/errors.go",
+	}
+	if !reflect.DeepEqual(context.Files(), expected) {
+		t.Errorf("expected %v, got %v", expected, context.Files())
+	}
+}
+
+func TestBuildContextIgnorePatterns(t *testing.T) {
+	files := []string{"a.txt", "d.txt", "src/vendor/lib.go", "src/main.go", "logs/app.log", "logs/keep/app.log"}
+	context, err := NewBuildContext(files, "[!a-c]*.txt\n**/vendor\n/logs/*.log\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As in Docker, "!" is a character of the class rather than a negation
+	expected := []string{"d.txt", "logs/keep/app.log", "src/main.go"}
+	if !reflect.DeepEqual(context.Files(), expected) {
+		t.Errorf("expected %v, got %v", expected, context.Files())
+	}
+
+	if _, err := NewBuildContext(files, "!\n"); err == nil {
+		t.Error("expected an error for an empty exclusion")
+	}
+}
+
+func TestDockerfileCopyContext(t *testing.T) {
+	context, err := NewBuildContext(sampleContextFiles, sampleDockerignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := TranslateDockerfile(`FROM golang:1.17
+WORKDIR /src
+COPY go.* ./
+COPY cmd/ /src/cmd/
+ADD README.md /usr/share/doc/app/
+COPY docs/index.md /tmp/
+COPY . .
+`, DockerfileOptions{Context: context})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{
+		"touch 'go.mod';", "touch 'go.sum';",
+		"mkdir '/src/cmd';", "mkdir '/src/cmd/app';", "touch '/src/cmd/app/main.go';",
+		"touch '/usr/share/doc/app/README.md';",
+		"// warning: COPY source docs/index.md is not in the build context",
+		"mkdir 'cmd';", "mkdir 'cmd/app';", "mkdir 'docs';", "mkdir 'vendor';",
+		"mkdir 'vendor/github.com/pkg/errors';",
+		"touch '.dockerignore';", "touch 'Dockerfile';", "touch 'cmd/app/main.go';",
+		"touch 'docs/CHANGELOG.md';", "touch 'vendor/github.com/pkg/errors/errors.go';",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"gopkg.in/yaml.v2"
+)
+
+// DiscoverOptions configures the search for repositories to analyze.
+type DiscoverOptions struct {
+	// Code runs the query as a code search (ex: "filename:Dockerfile
+	// language:go") instead of a repository search (ex: "language:go
+	// stars:>500").
+	Code bool
+
+	// Repositories with fewer stars or last pushed before PushedAfter are
+	// left out, as are archived repositories and forks unless included.
+	MinStars        int
+	PushedAfter     time.Time
+	IncludeArchived bool
+	IncludeForks    bool
+
+	// Limit is the number of repositories to find at most, and PerPage the
+	// number of search results requested at a time.
+	Limit   int
+	PerPage int
+}
+
+// DiscoverRepos searches GitHub for repositories and returns the URLs of
+// those passing the filters, in the order of the search results.
+func DiscoverRepos(ctx context.Context, client *github.Client, query string, opts DiscoverOptions) ([]string, error) {
+	searchOpts := &github.SearchOptions{ListOptions: github.ListOptions{PerPage: opts.PerPage}}
+	seen := make(map[string]bool)
+	var urls []string
+	results := 0
+	for {
+		repos, resp, err := searchRepos(ctx, client, query, opts.Code, searchOpts)
+		if err != nil {
+			return urls, err
+		}
+		results += len(repos)
+
+		for _, repo := range repos {
+			if seen[repo.GetFullName()] {
+				continue
+			}
+			seen[repo.GetFullName()] = true
+
+			// Code search results only hold the name of the repository
+			if opts.Code && (opts.MinStars > 0 || !opts.PushedAfter.IsZero() || !opts.IncludeArchived || !opts.IncludeForks) {
+				var full *github.Repository
+				if err := DefaultRetrier.Do(ctx, func() (resp *github.Response, err error) {
+					full, resp, err = client.Repositories.Get(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+					return resp, err
+				}); err != nil {
+					return urls, fmt.Errorf("error getting %s: %v", repo.GetFullName(), err)
+				}
+				repo = full
+			}
+			if !opts.matches(repo) {
+				continue
+			}
+			urls = append(urls, fmt.Sprintf("https://github.com/%s", repo.GetFullName()))
+			if opts.Limit > 0 && len(urls) >= opts.Limit {
+				return urls, nil
+			}
+		}
+		if resp.NextPage == 0 || results >= maxSearchResults {
+			return urls, nil
+		}
+		searchOpts.Page = resp.NextPage
+	}
+}
+
+// searchRepos returns the repositories of a page of search results.
+func searchRepos(ctx context.Context, client *github.Client, query string, code bool, opts *github.SearchOptions) ([]*github.Repository, *github.Response, error) {
+	var repos []*github.Repository
+	var resp *github.Response
+	err := DefaultRetrier.Do(ctx, func() (*github.Response, error) {
+		repos = nil
+		if code {
+			result, r, err := client.Search.Code(ctx, query, opts)
+			for _, codeResult := range result.CodeResults {
+				repos = append(repos, codeResult.Repository)
+			}
+			resp = r
+			return r, err
+		}
+		result, r, err := client.Search.Repositories(ctx, query, opts)
+		for i := range result.Repositories {
+			repos = append(repos, &result.Repositories[i])
+		}
+		resp = r
+		return r, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error searching %q: %v", query, err)
+	}
+	return repos, resp, nil
+}
+
+// matches reports whether a repository passes the filters.
+func (opts DiscoverOptions) matches(repo *github.Repository) bool {
+	switch {
+	case repo == nil:
+		return false
+	case repo.GetStargazersCount() < opts.MinStars:
+		return false
+	case !opts.PushedAfter.IsZero() && repo.GetPushedAt().Before(opts.PushedAfter):
+		return false
+	case repo.GetArchived() && !opts.IncludeArchived:
+		return false
+	case repo.GetFork() && !opts.IncludeForks:
+		return false
+	}
+	return true
+}
+
+// AppendRepoList appends repository URLs to a repos file, creating it if
+// needed. URLs already in the file are skipped. Returns the URLs added.
+func AppendRepoList(filename string, urls []string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var repoList struct {
+		Repos []string `yaml:"repos"`
+	}
+	if err := yaml.Unmarshal(data, &repoList); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	listed := make(map[string]bool)
+	for _, url := range repoList.Repos {
+		listed[normalizeRepoURL(url)] = true
+	}
+
+	// Append to the file as it is written rather than rewriting it
+	text := string(data)
+	if strings.TrimSpace(text) == "" {
+		text = "repos:\n"
+	} else if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	var added []string
+	for _, url := range urls {
+		if listed[normalizeRepoURL(url)] {
+			continue
+		}
+		listed[normalizeRepoURL(url)] = true
+		added = append(added, url)
+		text += "  - " + url + "\n"
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return added, ioutil.WriteFile(filename, []byte(text), 0644)
+}
+
+// normalizeRepoURL returns the form of a repository URL used to compare it
+// with other URLs.
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+}
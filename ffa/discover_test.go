@@ -0,0 +1,124 @@
+package ffa
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiscoverRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/repositories", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"total_count": 4, "items": [
+				{"full_name": "golang/go", "stargazers_count": 100000, "pushed_at": "2021-09-01T00:00:00Z"},
+				{"full_name": "old/repo", "stargazers_count": 900, "pushed_at": "2015-01-01T00:00:00Z"}]}`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s?page=2>; rel="next"`, r.URL.Path))
+		fmt.Fprint(w, `{"total_count": 4, "items": [
+			{"full_name": "moby/moby", "stargazers_count": 60000, "pushed_at": "2021-09-01T00:00:00Z"},
+			{"full_name": "someone/moby", "stargazers_count": 600, "pushed_at": "2021-09-01T00:00:00Z", "fork": true},
+			{"full_name": "archived/repo", "stargazers_count": 700, "pushed_at": "2021-09-01T00:00:00Z", "archived": true},
+			{"full_name": "small/repo", "stargazers_count": 10, "pushed_at": "2021-09-01T00:00:00Z"}]}`)
+	})
+	client, _, _ := newFakeGitHub(t, mux)
+
+	opts := DiscoverOptions{MinStars: 500, PushedAfter: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	urls, err := DiscoverRepos(context.Background(), client, "language:go", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"https://github.com/moby/moby", "https://github.com/golang/go"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %v, got %v", expected, urls)
+	}
+
+	opts.IncludeForks, opts.IncludeArchived, opts.Limit = true, true, 2
+	urls, err = DiscoverRepos(context.Background(), client, "language:go", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"https://github.com/moby/moby", "https://github.com/someone/moby"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %v, got %v", expected, urls)
+	}
+}
+
+func TestDiscoverReposCode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/code", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total_count": 3, "items": [
+			{"path": "Dockerfile", "repository": {"full_name": "moby/moby", "name": "moby", "owner": {"login": "moby"}}},
+			{"path": "build/Dockerfile", "repository": {"full_name": "moby/moby", "name": "moby", "owner": {"login": "moby"}}},
+			{"path": "Dockerfile", "repository": {"full_name": "small/repo", "name": "repo", "owner": {"login": "small"}}}]}`)
+	})
+	mux.HandleFunc("/repos/moby/moby", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"full_name": "moby/moby", "stargazers_count": 60000}`)
+	})
+	// Failed requests are retried for the same repository
+	attempts := 0
+	mux.HandleFunc("/repos/small/repo", func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"full_name": "small/repo", "stargazers_count": 10}`)
+	})
+	client, _, _ := newFakeGitHub(t, mux)
+
+	opts := DiscoverOptions{Code: true, MinStars: 500}
+	urls, err := DiscoverRepos(context.Background(), client, "filename:Dockerfile language:go", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"https://github.com/moby/moby"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %v, got %v", expected, urls)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestAppendRepoList(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "repos.yaml")
+
+	// The file is created if it does not exist
+	added, err := AppendRepoList(filename, []string{"https://github.com/moby/moby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 {
+		t.Errorf("expected 1 repo to be added, got %v", added)
+	}
+
+	// Repos already listed are skipped and the file is appended to as is
+	existing := "# Repos to analyze\nrepos:\n  - https://github.com/moby/moby\n  - https://github.com/golang/go"
+	if err := ioutil.WriteFile(filename, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+	added, err = AppendRepoList(filename, []string{
+		"https://github.com/Moby/moby/",
+		"https://github.com/docker/compose",
+		"https://github.com/docker/compose",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"https://github.com/docker/compose"}; !reflect.DeepEqual(added, expected) {
+		t.Errorf("expected %v to be added, got %v", expected, added)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := existing + "\n  - https://github.com/docker/compose\n"; string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
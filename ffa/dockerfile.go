@@ -0,0 +1,832 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// archiveExtensions are the local archive formats ADD extracts automatically.
+var archiveExtensions = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".tar.xz", ".txz"}
+
+// DockerStage holds the translation of a single build stage of a Dockerfile.
+type DockerStage struct {
+	Name  string // name given with FROM ... AS name, if any
+	Image string // base image or stage the stage is built from
+
+	// Script holds the complete FFA script of the stage, starting with the
+	// script of the stage it is built from.
+	Script []string
+}
+
+// dockerStage holds the translation of a single build stage while the
+// Dockerfile is being translated.
+type dockerStage struct {
+	name  string
+	image string
+
+	// base holds the statements of the stage this stage is built from, script
+	// holds the statements translated from the stage's own instructions and
+	// requires holds assertions on the stage's final filesystem made by other
+	// stages that copy from it.
+	base     []string
+	script   []string
+	requires []string
+
+	// args holds the ARG values and env the ENV values visible in the stage.
+	args map[string]string
+	env  map[string]string
+
+	// shell is the command used to run shell form instructions.
+	shell []string
+
+	// entrypoint and cmd are the command line the image runs.
+	entrypoint []string
+	cmd        []string
+
+	// binaries holds the programs known to be in the PATH of the base image.
+	binaries map[string]bool
+}
+
+// label returns the name of the stage, or its index if it has no name.
+func (s *dockerStage) label(index int) string {
+	if s.name != "" {
+		return s.name
+	}
+	return strconv.Itoa(index)
+}
+
+// DockerfileOptions configures the translation of a Dockerfile.
+type DockerfileOptions struct {
+	// BuildArgs overrides the values of ARG instructions, like the
+	// --build-arg flag of docker build.
+	BuildArgs map[string]string
+
+	// RecordUser records USER instructions as diagnostics since the
+	// permissions of the user running later instructions are not modeled.
+	RecordUser bool
+
+	// Profiles describes the filesystem of base images. Stages built from a
+	// known image start with its directories and files, and its programs are
+	// not asserted to be missing.
+	Profiles *ProfileRegistry
+
+	// Context holds the files of the build context. Local sources of COPY
+	// and ADD are then translated into the files they create in the image.
+	Context *BuildContext
+}
+
+// dockerTranslator holds the state used while translating a Dockerfile.
+type dockerTranslator struct {
+	*shellTranslator
+	opts   DockerfileOptions
+	lex    *shell.Lex
+	stages []*dockerStage
+
+	// globalArgs holds the ARG values declared before the first FROM.
+	globalArgs map[string]string
+}
+
+// TranslateDockerfile translates the instructions of a Dockerfile that change
+// the filesystem of the image into an FFA script. The stages of multi-stage
+// Dockerfiles are translated one after the other, each starting with a stage
+// marker comment.
+func TranslateDockerfile(data string, opts DockerfileOptions) ([]string, error) {
+	stages, err := translateDockerStages(data, opts)
+
+	var ffaScript []string
+	for i, stage := range stages {
+		if len(stages) > 1 {
+			ffaScript = append(ffaScript, fmt.Sprintf("// stage %s: FROM %s", stage.label(i), stage.image))
+		}
+		ffaScript = append(ffaScript, stage.script...)
+		ffaScript = append(ffaScript, stage.requires...)
+	}
+	return ffaScript, err
+}
+
+// TranslateDockerfileStages translates each build stage of a Dockerfile into
+// its own FFA script. Stages built from a previous stage start with the
+// statements of that stage, and copies from other stages are checked against
+// the final filesystem of the stage they copy from.
+func TranslateDockerfileStages(data string, opts DockerfileOptions) ([]DockerStage, error) {
+	stages, err := translateDockerStages(data, opts)
+
+	var results []DockerStage
+	for i, stage := range stages {
+		var script []string
+		script = append(script, stage.base...)
+		script = append(script, stage.script...)
+		script = append(script, stage.requires...)
+		results = append(results, DockerStage{
+			Name:   stage.label(i),
+			Image:  stage.image,
+			Script: script,
+		})
+	}
+	return results, err
+}
+
+func translateDockerStages(data string, opts DockerfileOptions) ([]*dockerStage, error) {
+	// Parse the Dockerfile
+	commandList, err := ExtractAllCommandsFromDockerfile(data)
+	if err != nil {
+		log.Print(err)
+	}
+
+	t := &dockerTranslator{
+		shellTranslator: newShellTranslator(),
+		opts:            opts,
+		lex:             shell.NewLex('\\'),
+		globalArgs:      make(map[string]string),
+	}
+	err = t.translate(commandList)
+	return t.stages, err
+}
+
+// translate translates each instruction into the script of its stage.
+func (t *dockerTranslator) translate(commandList []Command) error {
+	for _, cmd := range commandList {
+		if cmd.Cmd == "from" {
+			cmd.Value = t.substitute(cmd.Value, t.globalArgs)
+		} else if substitutedInstructions[cmd.Cmd] {
+			cmd.Value = t.substitute(cmd.Value, t.vars())
+		}
+
+		switch cmd.Cmd {
+		case "from":
+			t.endStage()
+			stage := &dockerStage{
+				args:  make(map[string]string),
+				env:   make(map[string]string),
+				shell: defaultShell,
+			}
+			if len(cmd.Value) > 0 {
+				stage.image = cmd.Value[0]
+			}
+			if len(cmd.Value) == 3 && strings.EqualFold(cmd.Value[1], "as") {
+				stage.name = cmd.Value[2]
+			}
+			// Stages built from a previous stage start with its filesystem
+			// and environment
+			if parent := t.findStage(stage.image); parent != nil {
+				stage.base = append(append(stage.base, parent.base...), parent.script...)
+				for name, value := range parent.env {
+					stage.env[name] = value
+				}
+				stage.shell = parent.shell
+				stage.entrypoint = parent.entrypoint
+				stage.cmd = parent.cmd
+				stage.binaries = parent.binaries
+			} else if profile := t.opts.Profiles.Match(stage.image); profile != nil {
+				// Stages built from a known image start with its filesystem
+				for _, statement := range profile.Preamble() {
+					t.emit("%s", statement)
+				}
+				stage.binaries = profile.BinarySet()
+			}
+			t.stages = append(t.stages, stage)
+		case "arg":
+			t.translateArg(cmd)
+		case "env":
+			t.translateEnv(cmd)
+		case "shell":
+			if len(t.stages) > 0 && len(cmd.Value) > 0 {
+				t.stages[len(t.stages)-1].shell = cmd.Value
+			}
+		case "run":
+			if err := t.translateRun(cmd); err != nil {
+				t.endStage()
+				return err
+			}
+		case "volume":
+			for _, volume := range cmd.Value {
+				t.emit("mkdir %s;", ffaString(volume))
+			}
+		case "user":
+			if t.opts.RecordUser && len(cmd.Value) > 0 {
+				t.warn("USER %s runs the following instructions without root permissions", cmd.Value[0])
+			}
+		case "entrypoint", "cmd":
+			if len(t.stages) == 0 {
+				break
+			}
+			stage := t.stages[len(t.stages)-1]
+			command := cmd.Value
+			if !cmd.Json {
+				command = append(append([]string{}, stage.shell...), strings.Join(cmd.Value, " "))
+			}
+			if cmd.Cmd == "entrypoint" {
+				// Setting the entrypoint resets the command
+				stage.entrypoint = command
+				stage.cmd = nil
+			} else {
+				stage.cmd = command
+			}
+		case "workdir":
+			t.emit("cd %s;", ffaString(cmd.Value[0]))
+		case "copy":
+			t.translateCopy(cmd)
+		case "add":
+			t.translateAdd(cmd)
+		}
+	}
+	t.endStage()
+
+	// The program run by the final image must exist
+	if len(t.stages) > 0 {
+		stage := t.stages[len(t.stages)-1]
+		if executable := stage.executable(); isPath(executable) {
+			assertion := fmt.Sprintf("assert(exists %s);", ffaString(executable))
+			stage.requires = append(stage.requires, assertion)
+		}
+	}
+	return nil
+}
+
+// executable returns the program run by the stage's entrypoint or command.
+// Programs run by a shell are taken from the shell script.
+func (s *dockerStage) executable() string {
+	command := s.entrypoint
+	if len(command) == 0 {
+		command = s.cmd
+	}
+	if script, ok := shellScript(command); ok {
+		command = strings.Fields(script)
+	}
+	if len(command) == 0 {
+		return ""
+	}
+	return command[0]
+}
+
+// isPath reports whether a program is invoked by a relative or absolute path
+// rather than being looked up in the PATH.
+func isPath(program string) bool {
+	return strings.Contains(program, "/")
+}
+
+// defaultShell is the shell used to run shell form instructions unless it is
+// changed with SHELL.
+var defaultShell = []string{"/bin/sh", "-c"}
+
+// posixShells are the shells whose scripts can be translated.
+var posixShells = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "ash": true, "ksh": true, "mksh": true, "zsh": true,
+}
+
+// translateRun translates a RUN instruction. Shell form scripts are run by
+// the active shell, while exec form instructions run a single program, which
+// may itself be a shell running a script with -c.
+func (t *dockerTranslator) translateRun(cmd Command) error {
+	t.shellTranslator.env = t.vars()
+	t.shellTranslator.binaries = nil
+	if len(t.stages) > 0 {
+		t.shellTranslator.binaries = t.stages[len(t.stages)-1].binaries
+	}
+
+	// Mounts only exist while the instruction runs. Targets that already
+	// exist in the image are mounted over and are left in place afterwards.
+	var created []runMount
+	for _, mount := range t.parseMounts(cmd) {
+		if !t.pathExists(mount.target) {
+			t.emit("%s %s;", mount.create, ffaString(mount.target))
+			created = append(created, mount)
+		}
+	}
+	defer func() {
+		for _, mount := range created {
+			t.emit("rmr %s;", ffaString(mount.target))
+		}
+	}()
+
+	if len(cmd.Heredocs) > 0 {
+		return t.translateRunHeredocs(cmd)
+	}
+	if cmd.Json {
+		if script, ok := shellScript(cmd.Value); ok {
+			return t.translateScript(script)
+		}
+		if len(cmd.Value) > 0 && posixShells[path.Base(cmd.Value[0])] {
+			t.warn("exec form RUN %s does not run a script with -c", cmd.Value[0])
+			return nil
+		}
+		t.varbank = make(map[string]string)
+		t.translateCommand(cmd.Value)
+		return nil
+	}
+
+	shell := defaultShell
+	if len(t.stages) > 0 {
+		shell = t.stages[len(t.stages)-1].shell
+	}
+	if !posixShells[path.Base(shell[0])] {
+		t.warn("RUN uses the shell %s which is not POSIX compatible", shell[0])
+		return nil
+	}
+	return t.translateScript(strings.Join(cmd.Value, " "))
+}
+
+// runMount is a filesystem mounted while a RUN instruction runs.
+type runMount struct {
+	target string
+	create string // the statement that creates the target (mkdir or touch)
+}
+
+// pathStatementRegexp matches the statements creating or removing a literal
+// path, which is the last argument.
+var pathStatementRegexp = regexp.MustCompile(`^(mkdir|touch|rmr|cp '[^']*') '([^']*)';$`)
+
+// pathExists reports whether an absolute path exists in the filesystem
+// modeled by the statements of the current stage so far. A path exists if it
+// or a path under it was created and it was not removed afterwards.
+func (t *dockerTranslator) pathExists(target string) bool {
+	if !path.IsAbs(target) {
+		return false
+	}
+	var statements []string
+	if len(t.stages) > 0 {
+		stage := t.stages[len(t.stages)-1]
+		statements = append(append(statements, stage.base...), stage.script...)
+	}
+	statements = append(statements, t.ffaList...)
+
+	target = path.Clean(target)
+	exists := target == "/"
+	for _, statement := range statements {
+		match := pathStatementRegexp.FindStringSubmatch(statement)
+		if match == nil {
+			continue
+		}
+		p := path.Clean(match[2])
+		if match[1] == "rmr" {
+			if p == target || strings.HasPrefix(target, p+"/") || p == "/" {
+				exists = false
+			}
+		} else if p == target || strings.HasPrefix(p, target+"/") {
+			exists = true
+		}
+	}
+	return exists
+}
+
+// parseMounts parses the --mount flags of a RUN instruction
+// (ex: --mount=type=cache,target=/root/.cache).
+func (t *dockerTranslator) parseMounts(cmd Command) []runMount {
+	var mounts []runMount
+	for _, flag := range cmd.Flags {
+		if !strings.HasPrefix(flag, "--mount=") {
+			if !strings.HasPrefix(flag, "--network=") && !strings.HasPrefix(flag, "--security=") {
+				t.warn("unsupported flag %s for RUN", flag)
+			}
+			continue
+		}
+
+		options := make(map[string]string)
+		for _, field := range strings.Split(strings.TrimPrefix(flag, "--mount="), ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) == 2 {
+				options[strings.ToLower(kv[0])] = kv[1]
+			} else {
+				options[strings.ToLower(kv[0])] = "true"
+			}
+		}
+		mountType := options["type"]
+		if mountType == "" {
+			mountType = "bind"
+		}
+		mount := runMount{create: "mkdir"}
+		for _, key := range []string{"target", "dst", "destination"} {
+			if target, ok := options[key]; ok {
+				mount.target = target
+			}
+		}
+
+		// Secrets and SSH agent sockets are files with default locations
+		switch mountType {
+		case "secret":
+			mount.create = "touch"
+			if mount.target == "" && options["id"] != "" {
+				mount.target = path.Join("/run/secrets", options["id"])
+			}
+		case "ssh":
+			mount.create = "touch"
+			if mount.target == "" {
+				mount.target = "/run/buildkit/ssh_agent.0"
+			}
+		}
+		if mount.target == "" {
+			t.warn("RUN --mount of type %s has no target", mountType)
+			continue
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+// translateRunHeredocs translates a RUN instruction using heredocs. Heredocs
+// without a command are run as scripts, otherwise they are the input of the
+// command.
+func (t *dockerTranslator) translateRunHeredocs(cmd Command) error {
+	commandLine := strings.TrimSpace(heredocRegexp.ReplaceAllStringFunc(strings.Join(cmd.Value, " "), func(marker string) string {
+		// Keep the character matched before the marker
+		return strings.SplitN(marker, "<<", 2)[0]
+	}))
+	fields := strings.Fields(commandLine)
+	_, hasScript := shellScript(fields)
+	runsScripts := len(fields) == 0 || (posixShells[path.Base(fields[0])] && !hasScript)
+	if !runsScripts {
+		// Give the heredocs to the shell as they were written
+		script := strings.Join(cmd.Value, " ")
+		for _, heredoc := range cmd.Heredocs {
+			script += "\n" + heredoc.Content + heredoc.Name
+		}
+		return t.translateScript(script)
+	}
+
+	for _, heredoc := range cmd.Heredocs {
+		// Scripts starting with a shebang are run by their interpreter
+		if strings.HasPrefix(heredoc.Content, "#!") {
+			interpreter := strings.Fields(strings.SplitN(heredoc.Content, "\n", 2)[0][2:])
+			if len(interpreter) > 0 && path.Base(interpreter[0]) == "env" {
+				interpreter = interpreter[1:]
+			}
+			if len(interpreter) == 0 || !posixShells[path.Base(interpreter[0])] {
+				t.warn("RUN heredoc %s is not a shell script", heredoc.Name)
+				continue
+			}
+		}
+		if err := t.translateScript(heredoc.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heredocSource returns the heredoc a COPY or ADD source refers to, if any.
+func heredocSource(cmd Command, src string) (Heredoc, bool) {
+	match := heredocRegexp.FindStringSubmatch(src)
+	if !strings.HasPrefix(src, "<<") || match == nil {
+		return Heredoc{}, false
+	}
+	for _, heredoc := range cmd.Heredocs {
+		if heredoc.Name == match[3] {
+			return heredoc, true
+		}
+	}
+	return Heredoc{}, false
+}
+
+// shellScript returns the script of a command line that runs a POSIX shell
+// with -c, such as ["/bin/bash", "-c", "make && make install"].
+func shellScript(command []string) (string, bool) {
+	if len(command) == 0 || !posixShells[path.Base(command[0])] {
+		return "", false
+	}
+	for i, arg := range command[1:] {
+		// -c may be clustered with other flags (e.g. -ec)
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+			continue
+		}
+		if strings.ContainsRune(arg, 'c') && i+2 < len(command) {
+			return command[i+2], true
+		}
+	}
+	return "", false
+}
+
+// substitutedInstructions are the instructions whose arguments have variables
+// substituted before they are translated.
+var substitutedInstructions = map[string]bool{
+	"workdir": true, "copy": true, "add": true, "volume": true, "user": true,
+}
+
+// vars returns the variables visible to the current instruction. Before the
+// first FROM only the global ARG values are visible.
+func (t *dockerTranslator) vars() map[string]string {
+	vars := make(map[string]string)
+	if len(t.stages) == 0 {
+		for name, value := range t.globalArgs {
+			vars[name] = value
+		}
+		return vars
+	}
+	// ENV values always override ARG values
+	stage := t.stages[len(t.stages)-1]
+	for name, value := range stage.args {
+		vars[name] = value
+	}
+	for name, value := range stage.env {
+		vars[name] = value
+	}
+	return vars
+}
+
+// substitute replaces variables in the arguments of an instruction with
+// their values. Arguments that cannot be processed are left as they are.
+func (t *dockerTranslator) substitute(values []string, vars map[string]string) []string {
+	substituted := make([]string, len(values))
+	for i, value := range values {
+		word, err := t.lex.ProcessWordWithMap(value, vars)
+		if err != nil {
+			word = value
+		}
+		substituted[i] = word
+	}
+	return substituted
+}
+
+// translateArg declares build arguments. Arguments declared before the first
+// FROM are global and may only be used by FROM, unless they are declared
+// again inside a stage without a value.
+func (t *dockerTranslator) translateArg(cmd Command) {
+	for _, arg := range cmd.Value {
+		name, value := arg, ""
+		eq := strings.Index(arg, "=")
+		if eq >= 0 {
+			name = arg[:eq]
+			value = t.substitute([]string{arg[eq+1:]}, t.vars())[0]
+		}
+
+		// Build arguments override the default value
+		buildArg, hasBuildArg := t.opts.BuildArgs[name]
+		switch {
+		case hasBuildArg:
+			value = buildArg
+		case eq < 0 && len(t.stages) > 0:
+			globalValue, ok := t.globalArgs[name]
+			if !ok {
+				// The argument has no value
+				continue
+			}
+			value = globalValue
+		case eq < 0:
+			continue
+		}
+
+		if len(t.stages) == 0 {
+			t.globalArgs[name] = value
+		} else {
+			t.stages[len(t.stages)-1].args[name] = value
+		}
+	}
+}
+
+// translateEnv sets environment variables for the rest of the stage and the
+// stages built from it.
+func (t *dockerTranslator) translateEnv(cmd Command) {
+	if len(t.stages) == 0 {
+		return
+	}
+	stage := t.stages[len(t.stages)-1]
+
+	// All values are substituted before any of them is set
+	values := t.substitute(cmd.Value, t.vars())
+	for i := 0; i+1 < len(values); i += 2 {
+		stage.env[values[i]] = values[i+1]
+	}
+}
+
+// endStage moves the statements translated so far into the current stage.
+func (t *dockerTranslator) endStage() {
+	if len(t.ffaList) == 0 {
+		return
+	}
+	if len(t.stages) == 0 {
+		// Instructions before the first FROM
+		t.stages = append(t.stages, &dockerStage{})
+	}
+	stage := t.stages[len(t.stages)-1]
+	stage.script = append(stage.script, t.ffaList...)
+	t.ffaList = nil
+}
+
+// findStage finds a previous build stage by its name or index.
+func (t *dockerTranslator) findStage(ref string) *dockerStage {
+	for i, stage := range t.stages {
+		if (stage.name != "" && strings.EqualFold(stage.name, ref)) || strconv.Itoa(i) == ref {
+			return stage
+		}
+	}
+	return nil
+}
+
+// copyFlags lists the flags understood for COPY and ADD.
+var copyFlags = []string{"--from", "--chown", "--chmod", "--link", "--checksum", "--keep-git-dir"}
+
+// parseCopyFlags parses the flags of a COPY or ADD instruction into a map.
+// Unknown flags are reported as warnings.
+func (t *dockerTranslator) parseCopyFlags(cmd Command) map[string]string {
+	flags := make(map[string]string)
+	for _, flag := range cmd.Flags {
+		name, value := flag, ""
+		if eq := strings.Index(flag, "="); eq >= 0 {
+			name, value = flag[:eq], flag[eq+1:]
+		}
+		known := false
+		for _, copyFlag := range copyFlags {
+			known = known || name == copyFlag
+		}
+		if !known {
+			t.warn("unsupported flag %s for %s", name, strings.ToUpper(cmd.Cmd))
+			continue
+		}
+		flags[name] = value
+	}
+	return flags
+}
+
+// translateCopy translates a COPY instruction.
+func (t *dockerTranslator) translateCopy(cmd Command) {
+	t.copySources(cmd, nil)
+}
+
+// translateAdd translates an ADD instruction. Local files are copied like
+// COPY, remote URLs are downloaded to the destination and local tar archives
+// are extracted into the destination directory.
+func (t *dockerTranslator) translateAdd(cmd Command) {
+	t.copySources(cmd, t.addSource)
+}
+
+// addSource translates the sources ADD does not copy as they are. Returns
+// false for sources that are copied like COPY.
+func (t *dockerTranslator) addSource(src, dest string, destIsDir bool) bool {
+	switch {
+	case isGitURL(src):
+		// Git repositories are cloned into the destination directory
+		t.emit("mkdir %s;", ffaString(dest))
+	case isRemoteURL(src):
+		target := dest
+		if name := remoteFilename(src); destIsDir && name != "" {
+			target = path.Join(dest, name)
+		}
+		t.emit("touch %s;", ffaString(target))
+	case isArchive(src):
+		// The contents of the archive are unknown
+		contents := t.newVar()
+		t.emit("%s = %s + INPUT;", contents, ffaString(strings.TrimSuffix(dest, "/")+"/"))
+		t.emit("touch %s;", contents)
+	default:
+		return false
+	}
+	return true
+}
+
+// copySources translates the sources of a COPY or ADD instruction. Sources
+// are copied from the build context, or from another stage or image when
+// --from is given. Sources of the build context handled by special are not
+// copied.
+func (t *dockerTranslator) copySources(cmd Command, special func(src, dest string, destIsDir bool) bool) {
+	if len(cmd.Value) < 2 {
+		return
+	}
+	flags := t.parseCopyFlags(cmd)
+	srcs := cmd.Value[:len(cmd.Value)-1]
+	dest := cmd.Value[len(cmd.Value)-1]
+
+	// The destination is a directory if it ends with a slash or if there are
+	// multiple sources
+	destIsDir := strings.HasSuffix(dest, "/") || len(srcs) > 1
+
+	from, ok := flags["--from"]
+	if !ok {
+		for _, src := range srcs {
+			// Heredocs create a file named after their delimiter
+			if heredoc, ok := heredocSource(cmd, src); ok {
+				t.emit("touch %s;", ffaString(copyTarget(heredoc.Name, dest, destIsDir)))
+				continue
+			}
+			if special != nil && special(src, dest, destIsDir) {
+				continue
+			}
+			if t.opts.Context != nil {
+				t.copyContext(cmd, src, dest, destIsDir)
+				continue
+			}
+			t.emit("cp %s %s;", ffaString(src), ffaString(copyTarget(src, dest, destIsDir)))
+		}
+		return
+	}
+
+	// Copies from another stage require the sources to exist in that stage,
+	// while images are not modeled
+	stage := t.findStage(from)
+	for _, src := range srcs {
+		if stage != nil {
+			// Sources are relative to the root of the other stage
+			assertion := fmt.Sprintf("assert(exists %s);", ffaString(path.Join("/", src)))
+			stage.requires = appendFFAList(stage.requires, assertion)
+		}
+		target := copyTarget(src, dest, destIsDir)
+		if strings.HasSuffix(src, "/") {
+			t.emit("mkdir %s;", ffaString(target))
+		} else {
+			t.emit("touch %s;", ffaString(target))
+		}
+	}
+}
+
+// copyContext translates the copy of a source from the build context into
+// the directories and files it creates in the image.
+func (t *dockerTranslator) copyContext(cmd Command, src, dest string, destIsDir bool) {
+	sources := t.opts.Context.sources(src)
+	if len(sources) == 0 {
+		t.warn("%s source %s is not in the build context", strings.ToUpper(cmd.Cmd), src)
+		return
+	}
+	// Wildcards matching several files copy them into a directory
+	destIsDir = destIsDir || len(sources) > 1
+
+	root := path.Clean(dest)
+	dirs := make(map[string]bool)
+	var files []string
+	for _, source := range sources {
+		target := copyTarget(source.file, dest, destIsDir)
+		if source.dir {
+			target = path.Join(dest, source.rel)
+		}
+		files = append(files, target)
+
+		// Create the directories between the destination and the file
+		for dir := path.Dir(target); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if dir != root && !strings.HasPrefix(dir, root+"/") && root != "." && root != "/" {
+				break
+			}
+			dirs[dir] = true
+		}
+	}
+
+	sortedDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Strings(sortedDirs)
+	for _, dir := range sortedDirs {
+		t.emit("mkdir %s;", ffaString(dir))
+	}
+	for _, file := range files {
+		t.emit("touch %s;", ffaString(file))
+	}
+}
+
+// copyTarget returns the path a source is copied to. Files copied into a
+// directory keep their name, while the contents of directories are copied
+// into the destination itself.
+func copyTarget(src, dest string, destIsDir bool) string {
+	if !destIsDir || src == "." || strings.HasSuffix(src, "/") {
+		return dest
+	}
+	return path.Join(dest, path.Base(src))
+}
+
+func isRemoteURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func isGitURL(src string) bool {
+	return strings.HasPrefix(src, "git@") || strings.HasPrefix(src, "git://") ||
+		(isRemoteURL(src) && strings.HasSuffix(strings.SplitN(src, "#", 2)[0], ".git"))
+}
+
+func isArchive(src string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(src), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteFilename returns the name of the file downloaded from a URL, or an
+// empty string if the URL has no path.
+func remoteFilename(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return ""
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return ""
+}
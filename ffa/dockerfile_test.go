@@ -0,0 +1,310 @@
+package ffa
+
+import (
+	"strings"
+	"testing"
+)
+
+var sampleAddDockerfile = `FROM alpine:3.14
+WORKDIR /app
+ADD https://github.com/rodneyxr/releases/app.tar.gz /tmp/
+ADD https://github.com/rodneyxr/config.yaml /etc/app.yaml
+ADD rootfs.tar.xz /
+ADD go.mod go.sum ./
+ADD scripts/ /usr/local/bin/
+ADD https://github.com/rodneyxr/repo.git /src/repo
+`
+
+func getDockerFFAScript(t *testing.T, data string) []string {
+	script, err := TranslateDockerfile(data, DockerfileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestDockerfileAdd(t *testing.T) {
+	script := getDockerFFAScript(t, sampleAddDockerfile)
+	tokens := []string{
+		"cd '/app';",
+		"touch '/tmp/app.tar.gz';",
+		"touch '/etc/app.yaml';",
+		"$x0 = '/' + INPUT;", "touch $x0;",
+		"cp 'go.mod' 'go.mod';", "cp 'go.sum' 'go.sum';",
+		"cp 'scripts/' '/usr/local/bin/';",
+		"mkdir '/src/repo';",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestDockerfileVariablesPerRun(t *testing.T) {
+	script := getDockerFFAScript(t, sampleDockerfile+`RUN A=/a && mkdir $A
+RUN B=/b && mkdir $B
+`)
+	tokens := []string{"$x0 = '/a';", "mkdir $x0;", "$x1 = '/b';", "mkdir $x1;"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+var sampleMultiStageDockerfile = `FROM golang:1.17 AS builder
+WORKDIR /src
+COPY go.mod go.sum ./
+COPY --chown=app:app --chmod=755 cmd/ ./cmd/
+RUN go build -o /out/app ./cmd/app
+FROM alpine:3.14
+COPY --from=builder /out/app /usr/bin/
+COPY --from=nginx:latest /etc/nginx/nginx.conf /etc/nginx/
+COPY --link config.yaml /etc/app/config.yaml
+`
+
+func TestDockerfileCopy(t *testing.T) {
+	script := getDockerFFAScript(t, sampleMultiStageDockerfile)
+	tokens := []string{
+		"cd '/src';",
+		"cp 'go.mod' 'go.mod';", "cp 'go.sum' 'go.sum';",
+		"cp 'cmd/' './cmd/';",
+		"assert(exists '/out/app');",
+		"touch '/usr/bin/app';",
+		"touch '/etc/nginx/nginx.conf';",
+		"cp 'config.yaml' '/etc/app/config.yaml';",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "warning") || strings.Contains(line, "exists '/etc/nginx") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+func TestDockerfileAddFrom(t *testing.T) {
+	stages, err := TranslateDockerfileStages(`FROM golang:1.17 AS builder
+RUN go build -o /out/app
+FROM alpine:3.14
+ADD --from=builder /out/app /app/
+`, DockerfileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ADD shares the handling of --from with COPY
+	if verifyTokens([]string{"assert(exists '/out/app');"}, stages[0].Script) != 1 {
+		t.Errorf("expected the builder to require /out/app, got %q", stages[0].Script)
+	}
+	if verifyTokens([]string{"touch '/app/app';"}, stages[1].Script) != 1 {
+		t.Errorf("expected /app/app to be created, got %q", stages[1].Script)
+	}
+}
+
+func TestDockerfileStages(t *testing.T) {
+	stages, err := TranslateDockerfileStages(sampleMultiStageDockerfile+`FROM builder AS test
+RUN rm -rf /out
+`, DockerfileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 3 {
+		t.Fatalf("expected 3 stages, got %d", len(stages))
+	}
+	if stages[0].Name != "builder" || stages[1].Name != "1" || stages[2].Image != "builder" {
+		t.Errorf("unexpected stages %v", stages)
+	}
+
+	// The builder must contain the file copied by the final stage
+	tokens := []string{"cd '/src';", "assert(! exists 'go');", "assert(exists '/out/app');"}
+	tokenCount := verifyTokens(tokens, stages[0].Script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	// The final stage does not see the files of the builder
+	for _, line := range stages[1].Script {
+		if strings.Contains(line, "/src") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+
+	// Stages built from the builder start with its statements
+	tokens = []string{"cd '/src';", "rmr '/out';"}
+	tokenCount = verifyTokens(tokens, stages[2].Script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range stages[2].Script {
+		if strings.Contains(line, "assert(exists '/out/app')") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+
+	// The combined script marks where each stage starts
+	script := getDockerFFAScript(t, sampleMultiStageDockerfile)
+	tokens = []string{"// stage builder: FROM golang:1.17", "// stage 1: FROM alpine:3.14"}
+	tokenCount = verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+var sampleEnvDockerfile = `ARG GO_VERSION=1.17
+FROM golang:${GO_VERSION} AS builder
+ARG GO_VERSION
+ARG APP_HOME=/app
+ENV GOPATH=/go SRC_DIR=$APP_HOME/src
+WORKDIR $APP_HOME
+COPY . ${SRC_DIR}
+RUN mkdir $GOPATH/bin && touch ${CONFIG:-/etc/app.conf}
+FROM builder
+RUN mkdir $GOPATH/pkg $APP_HOME/pkg
+`
+
+func TestDockerfileEnv(t *testing.T) {
+	stages, err := TranslateDockerfileStages(sampleEnvDockerfile, DockerfileOptions{
+		BuildArgs: map[string]string{"APP_HOME": "/srv"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stages[0].Image != "golang:1.17" {
+		t.Errorf("unexpected image %s", stages[0].Image)
+	}
+
+	tokens := []string{"cd '/srv';", "cp '.' '/srv/src';", "mkdir '/go/bin';", "touch '/etc/app.conf';"}
+	tokenCount := verifyTokens(tokens, stages[0].Script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	// ENV values are inherited by the next stage, but ARG values are not
+	tokens = []string{"mkdir '/go/pkg';", "mkdir '$APP_HOME/pkg';"}
+	tokenCount = verifyTokens(tokens, stages[1].Script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+var sampleExecFormDockerfile = `FROM debian:bullseye
+RUN ["/bin/bash", "-o", "pipefail", "-c", "mkdir -p /opt/app && touch /opt/app/ready"]
+RUN ["/usr/local/bin/setup.sh", "--quiet"]
+RUN ["touch", "/opt/app/exec"]
+SHELL ["/bin/bash", "-ec"]
+RUN mkdir /opt/bash
+FROM mcr.microsoft.com/windows/servercore:ltsc2019
+SHELL ["powershell", "-Command"]
+RUN New-Item -ItemType Directory -Path C:\app
+`
+
+func TestDockerfileRunForms(t *testing.T) {
+	script := getDockerFFAScript(t, sampleExecFormDockerfile)
+	tokens := []string{
+		"mkdir '/opt/app';", "touch '/opt/app/ready';",
+		"assert(exists '/usr/local/bin/setup.sh');",
+		"touch '/opt/app/exec';",
+		"mkdir '/opt/bash';",
+		"// warning: RUN uses the shell powershell",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "New-Item") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+func TestDockerfileHeredoc(t *testing.T) {
+	script := getDockerFFAScript(t, sampleHeredocDockerfile)
+	tokens := []string{
+		"mkdir '/opt/app';", "touch '/opt/app/ready';",
+		"// warning: RUN heredoc EOF is not a shell script",
+		"touch '/etc/app.conf';",
+		"touch '/opt/app/first.txt';", "touch '/opt/app/second.txt';",
+		"assert(! exists 'cat');", "assert(exists '/opt/app/motd');",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "print") || strings.Contains(line, "listen") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+var sampleRuntimeDockerfile = `FROM golang:1.17 AS builder
+WORKDIR /src
+RUN --mount=type=cache,target=/root/.cache/go-build --mount=type=secret,id=netrc go build -o /out/app
+ENTRYPOINT ["/bin/false"]
+FROM alpine:3.14
+VOLUME /data
+VOLUME ["/var/log/app"]
+COPY --from=builder /out/app /app/
+USER app
+ENTRYPOINT ["/app/app"]
+CMD ["--help"]
+`
+
+func TestDockerfileRuntime(t *testing.T) {
+	script, err := TranslateDockerfile(sampleRuntimeDockerfile, DockerfileOptions{RecordUser: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{
+		"mkdir '/root/.cache/go-build';", "touch '/run/secrets/netrc';",
+		"assert(! exists 'go');",
+		"rmr '/root/.cache/go-build';", "rmr '/run/secrets/netrc';",
+		"assert(exists '/out/app');",
+		"mkdir '/data';", "mkdir '/var/log/app';",
+		"touch '/app/app';",
+		"// warning: USER app",
+		"assert(exists '/app/app');",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "/bin/false") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+
+	// USER instructions are only recorded when requested
+	script = getDockerFFAScript(t, sampleRuntimeDockerfile)
+	for _, line := range script {
+		if strings.Contains(line, "USER") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+
+	// Mounts over directories that exist in the image are not removed
+	script, err = TranslateDockerfile(`FROM debian:bullseye
+COPY . /src
+RUN --mount=type=bind,target=/src --mount=type=cache,target=/var/cache/apt --mount=type=cache,target=/root/.cache make
+`, DockerfileOptions{Profiles: getProfileRegistry(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range script {
+		if line == "rmr '/src';" || line == "rmr '/var/cache/apt';" || line == "mkdir '/src';" {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+	if script[len(script)-1] != "rmr '/root/.cache';" {
+		t.Errorf("expected the new mount target to be removed, got %s", script[len(script)-1])
+	}
+
+	// Programs run by a shell form command are taken from the script
+	script = getDockerFFAScript(t, "FROM alpine\nWORKDIR /app\nCMD ./run.sh --port 80\n")
+	if script[len(script)-1] != "assert(exists './run.sh');" {
+		t.Errorf("unexpected statement %s", script[len(script)-1])
+	}
+}
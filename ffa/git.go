@@ -15,16 +15,17 @@
 package ffa
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"math"
+	"net/http"
 	"os"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -35,14 +36,123 @@ import (
 
 // Repos holds information about a GitHub repository
 type Repo struct {
-	URL         string     `json:"url"`
-	Owner       string     `json:"owner"`
-	Repo        string     `json:"repo"`
-	Languages   []Language `json:"languages"`
-	Dockerfiles []string   `json:"dockerfiles"`
-	Images      []string   `json:"images"`
+	URL         string      `json:"url"`
+	Owner       string      `json:"owner"`
+	Repo        string      `json:"repo"`
+	Languages   []Language  `json:"languages"`
+	Dockerfiles []FileEntry `json:"dockerfiles"`
+	Images      []Image     `json:"images"`
+
+	// Scripts and Makefiles are only collected when their kind is requested.
+	// Kinds are the kinds of files collected.
+	Scripts   []FileEntry `json:"scripts,omitempty"`
+	Makefiles []FileEntry `json:"makefiles,omitempty"`
+	Kinds     []string    `json:"kinds,omitempty"`
+
+	// Tree holds the paths of the files of the repo and Dockerignore its
+	// root .dockerignore file, which make up the build context of its
+	// Dockerfiles. They are recorded along with the Dockerfiles.
+	Tree         []string   `json:"tree,omitempty"`
+	Dockerignore *FileEntry `json:"dockerignore,omitempty"`
+
+	// Commit is the latest commit of the default branch when the repo was
+	// fetched, and FetchedAt the time it was last fetched or found unchanged.
+	Commit    string    `json:"commit,omitempty"`
+	FetchedAt time.Time `json:"fetched_at,omitempty"`
+
+	// Truncated reports whether the code search found more files than it
+	// could return, and TreeTruncated whether the tree listing did.
+	Truncated     bool `json:"truncated,omitempty"`
+	TreeTruncated bool `json:"tree_truncated,omitempty"`
+}
+
+// FileEntry is a file collected from a repository.
+type FileEntry struct {
+	Path      string    `json:"path"`
+	BlobSHA   string    `json:"blob_sha"`             // SHA of the git blob of the content
+	CommitSHA string    `json:"commit_sha,omitempty"` // commit the file was fetched at, if known
+	Size      int       `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Content   string    `json:"content"`
+}
+
+// NewFileEntry creates the entry of a file fetched now.
+func NewFileEntry(path, commitSHA, content string) FileEntry {
+	return FileEntry{
+		Path:      path,
+		BlobSHA:   gitBlobSHA(content),
+		CommitSHA: commitSHA,
+		Size:      len(content),
+		FetchedAt: time.Now().UTC(),
+		Content:   content,
+	}
 }
 
+// UnmarshalJSON decodes a file entry. Files cached before their path was
+// recorded are stored as their content only.
+func (e *FileEntry) UnmarshalJSON(data []byte) error {
+	var content string
+	if err := json.Unmarshal(data, &content); err == nil {
+		*e = FileEntry{BlobSHA: gitBlobSHA(content), Size: len(content), Content: content}
+		return nil
+	}
+	type entry FileEntry
+	return json.Unmarshal(data, (*entry)(e))
+}
+
+// gitBlobSHA returns the SHA git gives a blob with the given content.
+func gitBlobSHA(content string) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(content))
+	io.WriteString(hash, content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Modes of finding the files of a repository.
+const (
+	// FetchModeSearch searches the code of the default branch for the files
+	// of each kind.
+	FetchModeSearch = "search"
+
+	// FetchModeTree lists the files of the repository at its latest commit
+	// and matches their names.
+	FetchModeTree = "tree"
+)
+
+// FetchOptions configures how repositories are fetched from GitHub.
+type FetchOptions struct {
+	// Mode is FetchModeSearch or FetchModeTree. Code search is used by
+	// default.
+	Mode string
+
+	// PerPage is the number of code search results requested at a time, up
+	// to 100.
+	PerPage int
+
+	// Kinds are the kinds of files to collect (ex: FileKindShell). Only
+	// Dockerfiles are collected by default.
+	Kinds []string
+}
+
+// dockerfilePatterns match the names of Dockerfiles. Lowercase names only
+// match without an extension so source files such as dockerfile.go are not
+// matched.
+var dockerfilePatterns = []string{"Dockerfile", "dockerfile", "*.Dockerfile", "*.dockerfile", "Dockerfile.*", "Containerfile"}
+
+// repoFile is a file of a repository along with the SHA of its blob and the
+// commit it was found at, if known. Executable is only known for files found
+// in the tree.
+type repoFile struct {
+	Path       string
+	SHA        string
+	Commit     string
+	Executable bool
+}
+
+// maxSearchResults is the number of results the code search API returns at
+// most for a query.
+const maxSearchResults = 1000
+
 // Language holds information about a language used by a GitHub repository
 type Language struct {
 	Name       string  `json:"name"`
@@ -67,7 +177,7 @@ func LoadRepoCache(filePath string) ([]Repo, error) {
 }
 
 // NewRepo creates the repo object given a URL
-func NewRepo(ctx context.Context, client *github.Client, url string) Repo {
+func NewRepo(ctx context.Context, client *github.Client, url string, opts FetchOptions) (Repo, error) {
 	tokens := strings.Split(url, "/")
 	owner := tokens[len(tokens)-2]
 	repo := tokens[len(tokens)-1]
@@ -76,86 +186,185 @@ func NewRepo(ctx context.Context, client *github.Client, url string) Repo {
 		Owner: owner,
 		Repo:  repo,
 	}
-	if err := LoadLanguages(ctx, client, &repoInfo); err != nil {
-		log.Println(err)
-	}
-	LoadDockerfiles(ctx, client, &repoInfo)
-	return repoInfo
-}
-
-// LoadDockerfiles loads all files matching dockerfile in their path
-func LoadDockerfiles(ctx context.Context, client *github.Client, repoInfo *Repo) {
-	// Get the list of docker files in the repo
-	var codeResults *github.CodeSearchResult
-	var success bool
-	var err error
-	for !success {
-		codeResults, _, err = client.Search.Code(ctx, fmt.Sprintf("dockerfile+in:path+repo:%s/%s", repoInfo.Owner, repoInfo.Repo), &github.SearchOptions{})
-		if rateLimitError, ok := err.(*github.RateLimitError); ok {
-			waitTime := int(math.Abs(float64(rateLimitError.Rate.Reset.Second())-float64(time.Now().Second()))) + 1
-			log.Printf("error searching code: api rate limit reached - sleeping for %d second(s)...", waitTime)
-			time.Sleep(time.Duration(waitTime) * time.Second)
-		} else {
-			success = true
+	if err := RefreshRepo(ctx, client, &repoInfo, opts); err != nil {
+		return repoInfo, err
+	}
+	return repoInfo, nil
+}
+
+// RefreshRepo updates a repo with its latest commit. Nothing else is fetched
+// if the commit did not change and the requested kinds of files were already
+// collected, and only the files whose content changed are downloaded again.
+func RefreshRepo(ctx context.Context, client *github.Client, repoInfo *Repo, opts FetchOptions) error {
+	commit, changed, err := latestCommit(ctx, client, repoInfo)
+	if err != nil {
+		return err
+	}
+	if changed || !repoInfo.HasKinds(opts.Kinds) {
+		repoInfo.Commit = commit
+		if err := LoadLanguages(ctx, client, repoInfo); err != nil {
+			return err
+		}
+		if err := LoadFiles(ctx, client, repoInfo, opts); err != nil {
+			return err
+		}
+	}
+	repoInfo.FetchedAt = time.Now().UTC()
+	return nil
+}
+
+// latestCommit returns the latest commit of the default branch of a repo and
+// whether it is not the commit the repo was fetched at. The request is
+// conditional on the commit, so checking an unchanged repo does not count
+// against the rate limit.
+func latestCommit(ctx context.Context, client *github.Client, repoInfo *Repo) (string, bool, error) {
+	var commit string
+	if err := DefaultRetrier.Do(ctx, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		commit, resp, err = client.Repositories.GetCommitSHA1(ctx, repoInfo.Owner, repoInfo.Repo, "HEAD", repoInfo.Commit)
+		if resp != nil && resp.StatusCode == http.StatusNotModified {
+			return resp, nil
 		}
+		return resp, err
+	}); err != nil {
+		return "", false, fmt.Errorf("error getting the latest commit: %v", err)
+	}
+	if commit == "" {
+		// Not modified
+		return repoInfo.Commit, false, nil
 	}
+	return commit, commit != repoInfo.Commit, nil
+}
 
-	// Search for FROM statements in each docker file
-	var images []string
-	var dockerfiles []string
-	for _, result := range codeResults.CodeResults {
-		if strings.HasSuffix(*result.Path, ".go") {
-			continue
+// listFiles lists the files of a repository at the commit of the repo, or at
+// its latest commit if it has none. The commit is saved in the repo.
+func listFiles(ctx context.Context, client *github.Client, repoInfo *Repo) ([]repoFile, error) {
+	// Pin the latest commit of the default branch
+	commit := repoInfo.Commit
+	if commit == "" {
+		var err error
+		if commit, _, err = latestCommit(ctx, client, repoInfo); err != nil {
+			return nil, err
 		}
-		fmt.Println("\t", *result.Path)
-		success = false
-		var contents io.ReadCloser
-		for !success {
-			contents, err = client.Repositories.DownloadContents(ctx, repoInfo.Owner, repoInfo.Repo, *result.Path, &github.RepositoryContentGetOptions{})
-			if rateLimitError, ok := err.(*github.RateLimitError); ok {
-				waitTime := int(math.Abs(float64(rateLimitError.Rate.Reset.Second())-float64(time.Now().Second()))) + 1
-				log.Printf("error downloading contents: api rate limit reached - sleeping for %d second(s)...", waitTime)
-				time.Sleep(time.Duration(waitTime) * time.Second)
-			} else {
-				success = true
-			}
+	}
+
+	var tree *github.Tree
+	if err := DefaultRetrier.Do(ctx, func() (resp *github.Response, err error) {
+		tree, resp, err = client.Git.GetTree(ctx, repoInfo.Owner, repoInfo.Repo, commit, true)
+		return resp, err
+	}); err != nil {
+		return nil, fmt.Errorf("error listing the tree: %v", err)
+	}
+	repoInfo.Commit = commit
+	repoInfo.TreeTruncated = tree.GetTruncated()
+
+	var files []repoFile
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			files = append(files, repoFile{
+				Path:       entry.GetPath(),
+				SHA:        entry.GetSHA(),
+				Commit:     commit,
+				Executable: entry.GetMode() == "100755",
+			})
 		}
+	}
+	return files, nil
+}
 
-		// Save the dockerfile
-		var data []byte
-		data, err = ioutil.ReadAll(contents)
-		dockerfiles = append(dockerfiles, string(data))
-
-		// Extract the images
-		scanner := bufio.NewScanner(bytes.NewReader(data))
-		for scanner.Scan() {
-			text := scanner.Text()
-			if strings.HasPrefix(text, "FROM ") {
-				image := strings.Split(text, "FROM ")[1]
-				image = strings.Trim(image, " ")
-				images = append(images, image)
-				fmt.Println("\t\tFROM", image)
-			}
+// isDockerfileName reports whether a file name is the name of a Dockerfile
+// (ex: Dockerfile, Dockerfile.dev, app.Dockerfile or Containerfile).
+func isDockerfileName(name string) bool {
+	for _, pattern := range dockerfilePatterns {
+		if m, _ := path.Match(pattern, name); m {
+			return true
 		}
 	}
-	repoInfo.Dockerfiles = dockerfiles
-	repoInfo.Images = images
+	return false
+}
+
+// searchFiles searches the code of a repository for the files matching a
+// query (ex: "extension:sh"). The pages of results are fetched until the
+// search API's result limit, and truncated reports whether results are
+// missing.
+func searchFiles(ctx context.Context, client *github.Client, repoInfo *Repo, query string, opts FetchOptions) ([]repoFile, bool, error) {
+	query = fmt.Sprintf("%s+repo:%s/%s", query, repoInfo.Owner, repoInfo.Repo)
+	searchOpts := &github.SearchOptions{ListOptions: github.ListOptions{PerPage: opts.PerPage}}
+
+	var files []repoFile
+	results := 0
+	truncated := false
+	for {
+		var page *github.CodeSearchResult
+		var resp *github.Response
+		if err := DefaultRetrier.Do(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = client.Search.Code(ctx, query, searchOpts)
+			return resp, err
+		}); err != nil {
+			return nil, false, fmt.Errorf("error searching code: %v", err)
+		}
+		for _, result := range page.CodeResults {
+			files = append(files, repoFile{
+				Path:   result.GetPath(),
+				SHA:    result.GetSHA(),
+				Commit: htmlURLCommit(result.GetHTMLURL()),
+			})
+		}
+		results += len(page.CodeResults)
+
+		// Searches that time out return incomplete results
+		truncated = truncated || page.GetIncompleteResults()
+		if resp.NextPage == 0 || results >= maxSearchResults {
+			truncated = truncated || page.GetTotal() > results
+			break
+		}
+		searchOpts.Page = resp.NextPage
+	}
+	return files, truncated, nil
+}
+
+// htmlURLCommit returns the commit of a file's GitHub URL
+// (ex: https://github.com/owner/repo/blob/<commit>/path), if any.
+func htmlURLCommit(htmlURL string) string {
+	parts := strings.Split(htmlURL, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "blob" && len(parts[i+1]) == 40 {
+			return parts[i+1]
+		}
+	}
+	return ""
 }
 
 // LoadLanguages loads all the languages in the repository
 func LoadLanguages(ctx context.Context, client *github.Client, repoInfo *Repo) error {
 	// List the languages for the repo
-	languages, _, err := client.Repositories.ListLanguages(ctx, repoInfo.Owner, repoInfo.Repo)
-	if err != nil {
-		return err
+	var languages map[string]int
+	if err := DefaultRetrier.Do(ctx, func() (resp *github.Response, err error) {
+		languages, resp, err = client.Repositories.ListLanguages(ctx, repoInfo.Owner, repoInfo.Repo)
+		return resp, err
+	}); err != nil {
+		return fmt.Errorf("error listing languages: %v", err)
 	}
 
+	repoInfo.Languages = languagePercentages(languages)
+	return nil
+}
+
+// languagePercentages computes the percentage of each language from the
+// number of bytes written in it.
+func languagePercentages(languages map[string]int) []Language {
 	// Calculate the total number bytes to be used later when computing the language percentage
 	totalBytes := 0
 	for _, byteData := range languages {
 		totalBytes += byteData
 	}
 
+	if totalBytes == 0 {
+		return nil
+	}
+
 	// Create the list of languages along with their percentages
 	var languageInfos []Language
 	for language, byteData := range languages {
@@ -167,10 +376,12 @@ func LoadLanguages(ctx context.Context, client *github.Client, repoInfo *Repo) e
 
 	// Sort the language info list from highest to lowest percentage
 	sort.Slice(languageInfos, func(i, j int) bool {
+		if languageInfos[i].Percentage == languageInfos[j].Percentage {
+			return languageInfos[i].Name < languageInfos[j].Name
+		}
 		return languageInfos[i].Percentage > languageInfos[j].Percentage
 	})
-	repoInfo.Languages = languageInfos
-	return nil
+	return languageInfos
 }
 
 // CreateClient authenticates and creates a client to use
@@ -0,0 +1,324 @@
+package ffa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeCodeSearch serves code search results in pages like the GitHub API,
+// which returns at most 1000 results.
+func fakeCodeSearch(total int) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/code", func(w http.ResponseWriter, r *http.Request) {
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		start := (page - 1) * perPage
+		end := start + perPage
+		if end > total {
+			end = total
+		}
+		if end < total && end < maxSearchResults {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?per_page=%d&page=%d>; rel="next"`, r.URL.Path, perPage, page+1))
+		}
+		var items []string
+		for i := start; i < end; i++ {
+			items = append(items, fmt.Sprintf(`{"path": "docker/%d/Dockerfile"}`, i))
+		}
+		fmt.Fprintf(w, `{"total_count": %d, "incomplete_results": false, "items": [%s]}`, total, strings.Join(items, ","))
+	})
+	return mux
+}
+
+func TestSearchFilesPagination(t *testing.T) {
+	tests := []struct {
+		total, perPage, expected int
+		truncated                bool
+	}{
+		{5, 2, 5, false},
+		{100, 100, 100, false},
+		{2500, 100, maxSearchResults, true},
+	}
+	for _, test := range tests {
+		client, _, _ := newFakeGitHub(t, fakeCodeSearch(test.total))
+		repo := Repo{Owner: "moby", Repo: "moby"}
+		results, truncated, err := searchFiles(context.Background(), client, &repo, "dockerfile+in:path", FetchOptions{PerPage: test.perPage})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != test.expected {
+			t.Errorf("expected %d results, got %d", test.expected, len(results))
+		}
+		if truncated != test.truncated {
+			t.Errorf("expected truncated to be %v for %d results", test.truncated, test.total)
+		}
+	}
+}
+
+func TestIsDockerfileName(t *testing.T) {
+	for _, name := range []string{"Dockerfile", "dockerfile", "Dockerfile.dev", "app.Dockerfile", "Containerfile"} {
+		if !isDockerfileName(name) {
+			t.Errorf("%s should be a Dockerfile", name)
+		}
+	}
+	for _, name := range []string{"dockerfile.go", "Dockerfiles", "docker-compose.yml", "Makefile"} {
+		if isDockerfileName(name) {
+			t.Errorf("%s should not be a Dockerfile", name)
+		}
+	}
+}
+
+func TestLoadFilesTree(t *testing.T) {
+	const commit = "9fceb02d0ae598e95dc970b74767f19372d61af8"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/commits/HEAD", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, commit)
+	})
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/trees/"+commit, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("recursive") != "1" {
+			t.Error("expected a recursive tree listing")
+		}
+		fmt.Fprint(w, `{"sha": "`+commit+`", "truncated": false, "tree": [
+			{"path": "Dockerfile", "type": "blob", "sha": "a1"},
+			{"path": "build", "type": "tree", "sha": "b0"},
+			{"path": "build/app.Dockerfile", "type": "blob", "sha": "b1"},
+			{"path": "ffa/dockerfile.go", "type": "blob", "sha": "c1"},
+			{"path": "scripts/install.sh", "type": "blob", "mode": "100644", "sha": "d1"},
+			{"path": "bin/run", "type": "blob", "mode": "100755", "sha": "e1"},
+			{"path": "bin/tool", "type": "blob", "mode": "100755", "sha": "f1"},
+			{"path": "LICENSE", "type": "blob", "mode": "100644", "sha": "g1"},
+			{"path": "Makefile", "type": "blob", "mode": "100644", "sha": "h1"},
+			{"path": ".dockerignore", "type": "blob", "mode": "100644", "sha": "i1"}
+		]}`)
+	})
+	blobs := map[string]string{
+		"a1": "FROM alpine:3.14\n",
+		"b1": "FROM golang:1.17 AS build\n",
+		"d1": "mkdir -p /opt/app\n",
+		"e1": "#!/bin/sh\nexec /opt/app/app\n",
+		"f1": "#!/usr/bin/env python3\nprint('tool')\n",
+		"h1": "build:\n\tgo build\n",
+		"i1": "bin\n",
+	}
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/repos/rodneyxr/ffatoolkit/git/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, blob)
+	})
+	client, _, _ := newFakeGitHub(t, mux)
+
+	repo := Repo{Owner: "rodneyxr", Repo: "ffatoolkit"}
+	if err := LoadFiles(context.Background(), client, &repo, FetchOptions{Mode: FetchModeTree, Kinds: FileKinds}); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Commit != commit {
+		t.Errorf("expected commit %s, got %s", commit, repo.Commit)
+	}
+	if len(repo.Dockerfiles) != 2 {
+		t.Fatalf("expected 2 dockerfiles, got %d", len(repo.Dockerfiles))
+	}
+	dockerfile := repo.Dockerfiles[1]
+	if dockerfile.Path != "build/app.Dockerfile" || dockerfile.Content != blobs["b1"] || dockerfile.CommitSHA != commit {
+		t.Errorf("unexpected dockerfile %+v", dockerfile)
+	}
+	if len(repo.Images) != 2 || repo.Images[1].Alias != "build" {
+		t.Errorf("unexpected images %+v", repo.Images)
+	}
+
+	// Executables without an extension are kept if they are shell scripts
+	if len(repo.Scripts) != 2 || repo.Scripts[0].Path != "scripts/install.sh" || repo.Scripts[1].Path != "bin/run" {
+		t.Errorf("unexpected scripts %+v", repo.Scripts)
+	}
+	if len(repo.Makefiles) != 1 || repo.Makefiles[0].Content != blobs["h1"] {
+		t.Errorf("unexpected makefiles %+v", repo.Makefiles)
+	}
+	if !repo.HasKinds(FileKinds) {
+		t.Errorf("expected every kind to be collected, got %v", repo.Kinds)
+	}
+
+	// The files of the repo make up the build context of the Dockerfiles
+	buildContext, err := repo.BuildContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buildContext == nil || containsString(buildContext.Files(), "bin/run") || !containsString(buildContext.Files(), "scripts/install.sh") {
+		t.Errorf("unexpected build context %v", repo.Tree)
+	}
+}
+
+func TestLoadFilesSearch(t *testing.T) {
+	blobs := map[string]string{
+		"a1": "FROM alpine:3.14\n",
+		"b1": "#!/bin/sh\nexec \"$@\"\n",
+	}
+	const commit = "9fceb02d0ae598e95dc970b74767f19372d61af8"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/commits/HEAD", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, commit)
+	})
+	trees := 0
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/trees/"+commit, func(w http.ResponseWriter, r *http.Request) {
+		trees++
+		fmt.Fprint(w, `{"sha": "`+commit+`", "truncated": true, "tree": [
+			{"path": "docker/Dockerfile-dev", "type": "blob", "sha": "a1"},
+			{"path": "dockerfiles/entrypoint.sh", "type": "blob", "sha": "b1"}
+		]}`)
+	})
+	mux.HandleFunc("/search/code", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total_count": 3, "incomplete_results": true, "items": [
+			{"path": "docker/Dockerfile-dev", "sha": "a1"},
+			{"path": "dockerfiles/entrypoint.sh", "sha": "b1"},
+			{"path": "ffa/dockerfile.go", "sha": "c1"}
+		]}`)
+	})
+	var downloaded []string
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		sha := strings.TrimPrefix(r.URL.Path, "/repos/rodneyxr/ffatoolkit/git/blobs/")
+		downloaded = append(downloaded, sha)
+		fmt.Fprint(w, blobs[sha])
+	})
+	client, _, _ := newFakeGitHub(t, mux)
+
+	// Every search result but source files is kept, whatever its name
+	repo := Repo{Owner: "rodneyxr", Repo: "ffatoolkit"}
+	if err := LoadFiles(context.Background(), client, &repo, FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Dockerfiles) != 2 || repo.Dockerfiles[0].Path != "docker/Dockerfile-dev" {
+		t.Errorf("unexpected dockerfiles %+v", repo.Dockerfiles)
+	}
+	if len(repo.Scripts) != 0 {
+		t.Errorf("unexpected scripts %+v", repo.Scripts)
+	}
+	if len(downloaded) != 2 {
+		t.Errorf("expected 2 blobs to be downloaded, got %v", downloaded)
+	}
+
+	if !repo.Truncated || repo.TreeTruncated {
+		t.Errorf("expected only the search to be truncated, got %v and %v", repo.Truncated, repo.TreeTruncated)
+	}
+
+	// The tree is only listed when the Dockerfiles copy from the build context
+	if trees != 0 || len(repo.Tree) != 0 {
+		t.Errorf("unexpected build context %v listed %d times", repo.Tree, trees)
+	}
+	blobs["a1"] = "FROM alpine:3.14\nCOPY . /app\n"
+	repo = Repo{Owner: "rodneyxr", Repo: "ffatoolkit"}
+	if err := LoadFiles(context.Background(), client, &repo, FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if trees != 1 || len(repo.Tree) != 2 || repo.Dockerignore != nil {
+		t.Errorf("unexpected build context %v listed %d times", repo.Tree, trees)
+	}
+	if !repo.TreeTruncated {
+		t.Error("expected the tree to be truncated")
+	}
+}
+
+func TestFileEntryUnmarshalLegacy(t *testing.T) {
+	var repo Repo
+	data := `{"dockerfiles": ["hello\n", {"path": "build/Dockerfile", "blob_sha": "a1", "content": "FROM scratch\n"}]}`
+	if err := json.Unmarshal([]byte(data), &repo); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Dockerfiles) != 2 {
+		t.Fatalf("expected 2 dockerfiles, got %d", len(repo.Dockerfiles))
+	}
+	legacy := repo.Dockerfiles[0]
+	if legacy.Content != "hello\n" || legacy.Size != 6 || legacy.BlobSHA != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("unexpected migrated dockerfile %+v", legacy)
+	}
+	if repo.Dockerfiles[1].Path != "build/Dockerfile" {
+		t.Errorf("unexpected dockerfile %+v", repo.Dockerfiles[1])
+	}
+}
+
+func TestHTMLURLCommit(t *testing.T) {
+	commit := htmlURLCommit("https://github.com/moby/moby/blob/9fceb02d0ae598e95dc970b74767f19372d61af8/contrib/Dockerfile")
+	if commit != "9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Errorf("unexpected commit %q", commit)
+	}
+}
+
+func TestRefreshRepo(t *testing.T) {
+	const oldCommit = "9fceb02d0ae598e95dc970b74767f19372d61af8"
+	const newCommit = "3f8e0a4a7d10a2a3b9e3d7f2f1d0c9b8a7e6d5c4"
+	dockerfile := "FROM alpine:3.14\n"
+	appDockerfile := "FROM golang:1.17 AS build\n"
+
+	requests := make(map[string]int)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/commits/HEAD", func(w http.ResponseWriter, r *http.Request) {
+		requests["commit"]++
+		if r.Header.Get("If-None-Match") == `"`+newCommit+`"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, newCommit)
+	})
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/languages", func(w http.ResponseWriter, r *http.Request) {
+		requests["languages"]++
+		fmt.Fprint(w, `{"Go": 100}`)
+	})
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/trees/"+newCommit, func(w http.ResponseWriter, r *http.Request) {
+		requests["tree"]++
+		fmt.Fprintf(w, `{"sha": "%s", "tree": [
+			{"path": "Dockerfile", "type": "blob", "sha": "%s"},
+			{"path": "build/app.Dockerfile", "type": "blob", "sha": "%s"}
+		]}`, newCommit, gitBlobSHA(dockerfile), gitBlobSHA(appDockerfile))
+	})
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/git/blobs/"+gitBlobSHA(appDockerfile), func(w http.ResponseWriter, r *http.Request) {
+		requests["blob"]++
+		fmt.Fprint(w, appDockerfile)
+	})
+	client, _, _ := newFakeGitHub(t, mux)
+
+	// Only the Dockerfile that changed since the cached commit is downloaded
+	repo := Repo{
+		Owner:  "rodneyxr",
+		Repo:   "ffatoolkit",
+		Commit: oldCommit,
+		Dockerfiles: []FileEntry{
+			NewFileEntry("Dockerfile", oldCommit, dockerfile),
+			NewFileEntry("build/app.Dockerfile", oldCommit, "FROM golang:1.16 AS build\n"),
+		},
+	}
+	opts := FetchOptions{Mode: FetchModeTree}
+	if err := RefreshRepo(context.Background(), client, &repo, opts); err != nil {
+		t.Fatal(err)
+	}
+	if requests["blob"] != 1 || requests["tree"] != 1 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+	if repo.Commit != newCommit || repo.FetchedAt.IsZero() {
+		t.Errorf("unexpected commit %s fetched at %s", repo.Commit, repo.FetchedAt)
+	}
+	if repo.Dockerfiles[0].CommitSHA != oldCommit || repo.Dockerfiles[1].Content != appDockerfile {
+		t.Errorf("unexpected dockerfiles %+v", repo.Dockerfiles)
+	}
+	if len(repo.Images) != 2 || repo.Images[1].Tag != "1.17" {
+		t.Errorf("unexpected images %+v", repo.Images)
+	}
+
+	// Nothing else is fetched when the commit did not change
+	fetchedAt := repo.FetchedAt
+	if err := RefreshRepo(context.Background(), client, &repo, opts); err != nil {
+		t.Fatal(err)
+	}
+	if requests["commit"] != 2 || requests["tree"] != 1 || requests["languages"] != 1 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+	if repo.FetchedAt.Before(fetchedAt) {
+		t.Error("expected the fetch time to be updated")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// defaultRegistry is the registry of images that do not name one.
+const defaultRegistry = "docker.io"
+
+// Image is the base image of a build stage as written in a FROM instruction.
+type Image struct {
+	Registry   string `json:"registry,omitempty"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Platform   string `json:"platform,omitempty"` // value of the --platform flag
+	Alias      string `json:"alias,omitempty"`    // name given with FROM ... AS name
+
+	// Stage reports whether the FROM references an earlier build stage
+	// instead of an image, in which case only the repository is set.
+	Stage bool `json:"stage,omitempty"`
+}
+
+// String returns the image reference (ex: "golang:1.17" or
+// "gcr.io/distroless/static@sha256:...").
+func (i Image) String() string {
+	ref := i.Repository
+	if i.Registry != "" && i.Registry != defaultRegistry {
+		ref = i.Registry + "/" + ref
+	} else {
+		ref = strings.TrimPrefix(ref, "library/")
+	}
+	if i.Tag != "" {
+		ref += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		ref += "@" + i.Digest
+	}
+	return ref
+}
+
+// UnmarshalJSON decodes an image record. Images cached before they were
+// parsed are stored as the text following FROM (ex: "golang AS build").
+func (i *Image) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*i = parseFromText(text)
+		return nil
+	}
+	type image Image
+	return json.Unmarshal(data, (*image)(i))
+}
+
+// ParseImageReference splits an image reference into its registry,
+// repository, tag and digest. Images on Docker Hub are given the default
+// registry and official images the library namespace.
+func ParseImageReference(ref string) Image {
+	var image Image
+	if at := strings.Index(ref, "@"); at >= 0 {
+		ref, image.Digest = ref[:at], ref[at+1:]
+	}
+	// The tag follows the last colon unless it is part of a registry port
+	if colon := strings.LastIndex(ref, ":"); colon >= 0 && !strings.Contains(ref[colon:], "/") {
+		ref, image.Tag = ref[:colon], ref[colon+1:]
+	}
+
+	// The first component names a registry if it looks like a host name
+	image.Registry = defaultRegistry
+	if slash := strings.Index(ref, "/"); slash >= 0 {
+		host := ref[:slash]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			image.Registry, ref = host, ref[slash+1:]
+		}
+	}
+	if image.Registry == defaultRegistry {
+		ref = strings.TrimPrefix(ref, "index.docker.io/")
+		if !strings.Contains(ref, "/") {
+			ref = "library/" + ref
+		}
+	}
+	image.Repository = ref
+	return image
+}
+
+// parseFromText parses the arguments of a FROM instruction.
+func parseFromText(text string) Image {
+	fields := strings.Fields(text)
+	var platform string
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		if strings.HasPrefix(fields[0], "--platform=") {
+			platform = strings.TrimPrefix(fields[0], "--platform=")
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return Image{Platform: platform}
+	}
+	image := ParseImageReference(fields[0])
+	image.Platform = platform
+	if len(fields) == 3 && strings.EqualFold(fields[1], "as") {
+		image.Alias = fields[2]
+	}
+	return image
+}
+
+// ExtractImagesFromDockerfile returns the base image of each build stage of
+// a Dockerfile. Variables are substituted with the default values of the
+// ARG instructions declared before the first FROM.
+func ExtractImagesFromDockerfile(data string) ([]Image, error) {
+	commandList, err := ExtractAllCommandsFromDockerfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lex := shell.NewLex('\\')
+	args := make(map[string]string)
+	stages := make(map[string]bool)
+	var images []Image
+	for _, cmd := range commandList {
+		switch cmd.Cmd {
+		case "arg":
+			if len(images) > 0 {
+				continue
+			}
+			for _, arg := range cmd.Value {
+				if eq := strings.Index(arg, "="); eq >= 0 {
+					args[arg[:eq]] = arg[eq+1:]
+				}
+			}
+		case "from":
+			if len(cmd.Value) == 0 {
+				continue
+			}
+			ref := cmd.Value[0]
+			if word, err := lex.ProcessWordWithMap(ref, args); err == nil && word != "" {
+				ref = word
+			}
+
+			var image Image
+			if stages[strings.ToLower(ref)] {
+				image = Image{Repository: ref, Stage: true}
+			} else {
+				image = ParseImageReference(ref)
+			}
+			for _, flag := range cmd.Flags {
+				if strings.HasPrefix(flag, "--platform=") {
+					image.Platform = strings.TrimPrefix(flag, "--platform=")
+				}
+			}
+			if len(cmd.Value) == 3 && strings.EqualFold(cmd.Value[1], "as") {
+				image.Alias = cmd.Value[2]
+				stages[strings.ToLower(image.Alias)] = true
+			}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,64 @@
+package ffa
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := map[string]Image{
+		"golang":                   {Registry: "docker.io", Repository: "library/golang"},
+		"golang:1.10.0":            {Registry: "docker.io", Repository: "library/golang", Tag: "1.10.0"},
+		"rodneyxr/app:latest":      {Registry: "docker.io", Repository: "rodneyxr/app", Tag: "latest"},
+		"localhost:5000/app":       {Registry: "localhost:5000", Repository: "app"},
+		"gcr.io/distroless/static": {Registry: "gcr.io", Repository: "distroless/static"},
+		"alpine:3.14@sha256:e1c082e3d3c4": {Registry: "docker.io", Repository: "library/alpine", Tag: "3.14",
+			Digest: "sha256:e1c082e3d3c4"},
+	}
+	for ref, expected := range tests {
+		image := ParseImageReference(ref)
+		if !reflect.DeepEqual(image, expected) {
+			t.Errorf("%s: expected %+v, got %+v", ref, expected, image)
+		}
+		if image.String() != ref {
+			t.Errorf("expected %s, got %s", ref, image.String())
+		}
+	}
+}
+
+func TestExtractImages(t *testing.T) {
+	images, err := ExtractImagesFromDockerfile(`ARG GO_VERSION=1.17
+from --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+RUN go build -o /out/app .
+FROM \
+    gcr.io/distroless/static
+FROM build AS test
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Image{
+		{Registry: "docker.io", Repository: "library/golang", Tag: "1.17", Platform: "$BUILDPLATFORM", Alias: "build"},
+		{Registry: "gcr.io", Repository: "distroless/static"},
+		{Repository: "build", Alias: "test", Stage: true},
+	}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected %+v, got %+v", expected, images)
+	}
+}
+
+func TestImageUnmarshalLegacy(t *testing.T) {
+	var repo Repo
+	data := `{"images": ["golang:1.10 AS build", {"registry": "gcr.io", "repository": "distroless/static"}]}`
+	if err := json.Unmarshal([]byte(data), &repo); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Image{
+		{Registry: "docker.io", Repository: "library/golang", Tag: "1.10", Alias: "build"},
+		{Registry: "gcr.io", Repository: "distroless/static"},
+	}
+	if !reflect.DeepEqual(repo.Images, expected) {
+		t.Errorf("expected %+v, got %+v", expected, repo.Images)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// languageExtensions maps file extensions to the language of the file, for
+// computing the languages of repositories without the GitHub API.
+var languageExtensions = map[string]string{
+	".go": "Go", ".sh": "Shell", ".bash": "Shell", ".py": "Python", ".js": "JavaScript", ".ts": "TypeScript",
+	".java": "Java", ".kt": "Kotlin", ".rb": "Ruby", ".rs": "Rust", ".c": "C", ".h": "C", ".cc": "C++",
+	".cpp": "C++", ".hpp": "C++", ".cs": "C#", ".php": "PHP", ".scala": "Scala", ".swift": "Swift",
+	".html": "HTML", ".css": "CSS", ".mk": "Makefile", ".pl": "Perl", ".lua": "Lua", ".proto": "Protocol Buffer",
+}
+
+// IsLocalSource reports whether a repository source is a local directory or
+// a file:// git URL rather than a GitHub repository.
+func IsLocalSource(source string) bool {
+	if strings.HasPrefix(source, "file://") {
+		return true
+	}
+	info, err := os.Stat(source)
+	return err == nil && info.IsDir()
+}
+
+// NewLocalRepo creates the repo object of a local directory or a file:// git
+// URL, which is cloned with the git command. The files of the given kinds are
+// found on disk and the languages are computed from the file extensions.
+func NewLocalRepo(ctx context.Context, source string, kinds []string) (Repo, error) {
+	// The owner and name are taken from the source so they do not depend on
+	// where it is cloned
+	dir := source
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Repo{}, err
+	}
+	owner, name := filepath.Base(filepath.Dir(abs)), filepath.Base(abs)
+	if strings.HasPrefix(source, "file://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return Repo{}, err
+		}
+		repoPath := strings.TrimSuffix(u.Path, "/")
+		owner, name = path.Base(path.Dir(repoPath)), path.Base(repoPath)
+
+		tmpDir, err := ioutil.TempDir("", "ffatoolkit")
+		if err != nil {
+			return Repo{}, err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		dir = filepath.Join(tmpDir, name)
+		clone := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", source, dir)
+		if output, err := clone.CombinedOutput(); err != nil {
+			return Repo{}, fmt.Errorf("error cloning %s: %v: %s", source, err, bytes.TrimSpace(output))
+		}
+	}
+
+	repoInfo := Repo{
+		URL:   source,
+		Owner: owner,
+		Repo:  strings.TrimSuffix(name, ".git"),
+	}
+
+	// Record the commit of git repositories
+	revParse := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD")
+	if output, err := revParse.Output(); err == nil {
+		repoInfo.Commit = strings.TrimSpace(string(output))
+	}
+
+	if err := loadLocalFiles(dir, &repoInfo, kinds); err != nil {
+		return repoInfo, err
+	}
+	repoInfo.FetchedAt = time.Now().UTC()
+	return repoInfo, nil
+}
+
+// loadLocalFiles walks a local repository to load its files of the given
+// kinds and its languages.
+func loadLocalFiles(dir string, repoInfo *Repo, kinds []string) error {
+	kinds, err := fileKindsOf(kinds)
+	if err != nil {
+		return err
+	}
+	collected := make(map[string]bool)
+	for _, kind := range kinds {
+		collected[kind] = true
+	}
+
+	languageBytes := make(map[string]int)
+	var tree []string
+	err = filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if collected[FileKindDockerfile] {
+			tree = append(tree, rel)
+		}
+		if language, ok := languageExtensions[strings.ToLower(filepath.Ext(filename))]; ok {
+			languageBytes[language] += int(info.Size())
+		}
+
+		// Files without an extension are shell scripts if they have a shebang
+		kind := ""
+		for _, name := range kinds {
+			if fileKinds[name].match(info.Name()) {
+				kind = name
+				break
+			}
+		}
+		if kind == "" && collected[FileKindShell] && filepath.Ext(filename) == "" {
+			isScript, err := hasShellShebang(filename)
+			if err != nil {
+				return err
+			}
+			if isScript {
+				kind = FileKindShell
+			}
+		}
+		if kind == "" {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		entries := fileKinds[kind].files(repoInfo)
+		*entries = append(*entries, NewFileEntry(rel, repoInfo.Commit, string(data)))
+		if kind == FileKindShell && filepath.Ext(filename) == "" {
+			languageBytes["Shell"] += len(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	repoInfo.Languages = languagePercentages(languageBytes)
+	repoInfo.Kinds = kinds
+	repoInfo.Tree = tree
+
+	// Record the build context of the Dockerfiles
+	if ignore, err := ioutil.ReadFile(filepath.Join(dir, ".dockerignore")); err == nil && collected[FileKindDockerfile] {
+		entry := NewFileEntry(".dockerignore", repoInfo.Commit, string(ignore))
+		repoInfo.Dockerignore = &entry
+	}
+
+	// Extract the images of the Dockerfiles
+	for _, dockerfile := range repoInfo.Dockerfiles {
+		images, err := ExtractImagesFromDockerfile(dockerfile.Content)
+		if err != nil {
+			log.Printf("error parsing %s: %v", dockerfile.Path, err)
+		}
+		repoInfo.Images = append(repoInfo.Images, images...)
+	}
+	return nil
+}
+
+// isShellScriptName reports whether a file name has a shell script extension.
+func isShellScriptName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".sh" || ext == ".bash"
+}
+
+// hasShellShebang reports whether a file starts with a shebang running a
+// POSIX shell (ex: #!/bin/sh or #!/usr/bin/env bash).
+func hasShellShebang(filename string) (bool, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if prefix, _ := reader.Peek(2); string(prefix) != "#!" {
+		return false, nil
+	}
+	line, _ := reader.ReadString('\n')
+	return isShellShebang(line), nil
+}
+
+// isShellShebang reports whether a script starts with a shebang running a
+// POSIX shell.
+func isShellShebang(script string) bool {
+	if !strings.HasPrefix(script, "#!") {
+		return false
+	}
+	line := strings.SplitN(script, "\n", 2)[0]
+	interpreter := strings.Fields(line[2:])
+	if len(interpreter) > 0 && path.Base(interpreter[0]) == "env" {
+		interpreter = interpreter[1:]
+	}
+	return len(interpreter) > 0 && posixShells[path.Base(interpreter[0])]
+}
@@ -0,0 +1,124 @@
+package ffa
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writeSampleRepo writes a repository with Dockerfiles, shell scripts and Go
+// sources to a temporary directory.
+func writeSampleRepo(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile":               "FROM golang:1.17 AS build\nFROM alpine:3.14\n",
+		"build/app.Dockerfile":     "FROM scratch\n",
+		"scripts/install.sh":       "mkdir -p /opt/app\n",
+		"bin/run":                  "#!/usr/bin/env bash\nexec /opt/app/app\n",
+		"bin/tool":                 "#!/usr/bin/env python3\nprint('tool')\n",
+		"main.go":                  "package main\n\nfunc main() {}\n",
+		"Makefile":                 "build:\n\tgo build\n",
+		"ffa/dockerfile.go":        "package ffa\n",
+		".git/hooks/pre-commit.sh": "exit 0\n",
+		".dockerignore":            "ffa\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewLocalRepo(t *testing.T) {
+	dir := writeSampleRepo(t)
+	repo, err := NewLocalRepo(context.Background(), dir, FileKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.URL != dir || repo.Repo != filepath.Base(dir) {
+		t.Errorf("unexpected repo %s (%s)", repo.URL, repo.Repo)
+	}
+	if len(repo.Dockerfiles) != 2 || len(repo.Images) != 3 {
+		t.Errorf("expected 2 Dockerfiles and 3 images, got %d and %v", len(repo.Dockerfiles), repo.Images)
+	}
+	if len(repo.Dockerfiles) == 2 && repo.Dockerfiles[1].Path != "build/app.Dockerfile" {
+		t.Errorf("unexpected path %s", repo.Dockerfiles[1].Path)
+	}
+	if len(repo.Scripts) != 2 || len(repo.Makefiles) != 1 {
+		t.Errorf("expected 2 scripts and 1 makefile, got %+v and %+v", repo.Scripts, repo.Makefiles)
+	}
+	if len(repo.Languages) != 2 || repo.Languages[0].Name != "Shell" || repo.Languages[1].Name != "Go" {
+		t.Errorf("unexpected languages %v", repo.Languages)
+	}
+
+	// The build context of the Dockerfiles is recorded without .git
+	buildContext, err := repo.BuildContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Tree) != 9 || buildContext == nil || containsString(buildContext.Files(), "ffa/dockerfile.go") {
+		t.Errorf("unexpected build context %v", repo.Tree)
+	}
+}
+
+func TestNewLocalRepoClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := writeSampleRepo(t)
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=ffatoolkit", "-c", "user.email=ffatoolkit@localhost", "commit", "--quiet", "-m", "sample"},
+	} {
+		git := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if output, err := git.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+
+	repo, err := NewLocalRepo(context.Background(), "file://"+filepath.ToSlash(dir), FileKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := filepath.Base(filepath.Dir(dir)); repo.Owner != owner || repo.Repo != filepath.Base(dir) {
+		t.Errorf("expected the repo %s/%s, got %s/%s", owner, filepath.Base(dir), repo.Owner, repo.Repo)
+	}
+	if len(repo.Commit) != 40 {
+		t.Errorf("expected the commit to be recorded, got %q", repo.Commit)
+	}
+	if len(repo.Dockerfiles) != 2 || len(repo.Scripts) != 2 {
+		t.Errorf("expected 2 Dockerfiles and 2 scripts, got %d and %d", len(repo.Dockerfiles), len(repo.Scripts))
+	}
+}
+
+func TestNewLocalRepoKinds(t *testing.T) {
+	dir := writeSampleRepo(t)
+
+	// Only Dockerfiles are collected by default
+	repo, err := NewLocalRepo(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Dockerfiles) != 2 || len(repo.Scripts) != 0 || len(repo.Makefiles) != 0 {
+		t.Errorf("expected only Dockerfiles, got %d, %d and %d", len(repo.Dockerfiles), len(repo.Scripts), len(repo.Makefiles))
+	}
+	if repo.HasKinds([]string{FileKindShell}) {
+		t.Error("shell scripts should not be collected")
+	}
+
+	if _, err := NewLocalRepo(context.Background(), dir, []string{"python"}); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
@@ -15,29 +15,168 @@
 package ffa
 
 import (
+	"regexp"
 	"strings"
 
 	"github.com/asottile/dockerfile"
 )
 
-// ExtractAllCommandsFromDockerfile
-func ExtractAllCommandsFromDockerfile(data string) ([]dockerfile.Command, error) {
-	reader := strings.NewReader(data)
-	commandList, err := dockerfile.ParseReader(reader)
+// Command is a single instruction in a Dockerfile along with the heredocs
+// attached to it.
+type Command struct {
+	dockerfile.Command
+	Heredocs []Heredoc
+}
+
+// Heredoc is a here-document used by a RUN, COPY or ADD instruction with the
+// BuildKit heredoc syntax (ex: `RUN <<EOF`).
+type Heredoc struct {
+	Name    string // the delimiter, which is the file name for COPY and ADD
+	Content string // the lines of the here-document
+	Expand  bool   // whether variables are expanded (the delimiter is not quoted)
+	Chomp   bool   // whether leading tabs are removed (`<<-`)
+}
+
+// heredocInstructions are the instructions that accept heredocs.
+var heredocInstructions = map[string]bool{"run": true, "copy": true, "add": true}
+
+// heredocRegexp matches the heredoc markers of an instruction (ex: <<EOF,
+// <<-"EOF"). Delimiters start with a letter or an underscore as in BuildKit,
+// so shifts such as 1<<2 are not matched. Here-strings (<<<) are not matched.
+var heredocRegexp = regexp.MustCompile(`(?:^|[^<])<<(-?)(["']?)([A-Za-z_][A-Za-z0-9_./-]*)(["']?)`)
+
+// ExtractAllCommandsFromDockerfile parses a Dockerfile into its list of
+// instructions. Instruction names are lowercased (ex: `run`).
+func ExtractAllCommandsFromDockerfile(data string) ([]Command, error) {
+	// The parser does not support heredocs so their contents are removed
+	// before parsing and attached to their instructions afterwards
+	source, heredocs, lineMap := extractHeredocs(data)
+
+	reader := strings.NewReader(source)
+	parsedList, err := dockerfile.ParseReader(reader)
 	if err != nil {
 		return nil, err
 	}
+
+	commandList := make([]Command, len(parsedList))
+	for i, parsed := range parsedList {
+		cmd := Command{Command: parsed, Heredocs: heredocs[parsed.StartLine]}
+
+		// The parser keeps the instruction names as they were written
+		cmd.Cmd = strings.ToLower(cmd.Cmd)
+		cmd.SubCmd = strings.ToLower(cmd.SubCmd)
+
+		// Report the lines of the original Dockerfile
+		if cmd.StartLine > 0 && cmd.StartLine <= len(lineMap) {
+			cmd.StartLine = lineMap[cmd.StartLine-1]
+		}
+		if cmd.EndLine > 0 && cmd.EndLine <= len(lineMap) {
+			cmd.EndLine = lineMap[cmd.EndLine-1]
+		}
+		if n := len(cmd.Heredocs); n > 0 {
+			cmd.EndLine += strings.Count(cmd.Heredocs[n-1].Content, "\n") + 1
+		}
+		commandList[i] = cmd
+	}
 	return commandList, nil
 }
 
+// extractHeredocs removes the contents of heredocs from a Dockerfile. Returns
+// the remaining source, the heredocs of each instruction by the line the
+// instruction starts at in the remaining source, and the original line number
+// of each remaining line.
+func extractHeredocs(data string) (string, map[int][]Heredoc, []int) {
+	lines := strings.Split(data, "\n")
+	heredocs := make(map[int][]Heredoc)
+	var kept []string
+	var lineMap []int
+
+	for i := 0; i < len(lines); i++ {
+		// Collect the instruction along with its continuation lines
+		start := len(kept) + 1
+		instruction := lines[i]
+		kept = append(kept, lines[i])
+		lineMap = append(lineMap, i+1)
+		for strings.HasSuffix(strings.TrimRight(lines[i], " \t"), "\\") && i+1 < len(lines) {
+			i++
+			instruction += "\n" + lines[i]
+			kept = append(kept, lines[i])
+			lineMap = append(lineMap, i+1)
+		}
+
+		fields := strings.Fields(instruction)
+		if len(fields) == 0 || !heredocInstructions[strings.ToLower(fields[0])] {
+			continue
+		}
+
+		// The contents of each heredoc follow the instruction in order. A
+		// heredoc without a terminator is not one, so its lines are kept.
+		for _, match := range heredocRegexp.FindAllStringSubmatch(stripArithmetic(instruction), -1) {
+			heredoc := Heredoc{
+				Name:   match[3],
+				Expand: match[2] == "",
+				Chomp:  match[1] == "-",
+			}
+			var content strings.Builder
+			terminated := false
+			for j := i + 1; j < len(lines); j++ {
+				line := strings.TrimSuffix(lines[j], "\r")
+				if heredoc.Chomp {
+					line = strings.TrimLeft(line, "\t")
+				}
+				if line == heredoc.Name {
+					i, terminated = j, true
+					break
+				}
+				content.WriteString(line + "\n")
+			}
+			if !terminated {
+				break
+			}
+			heredoc.Content = content.String()
+			heredocs[start] = append(heredocs[start], heredoc)
+		}
+	}
+	return strings.Join(kept, "\n"), heredocs, lineMap
+}
+
+// stripArithmetic blanks out the arithmetic of an instruction (ex:
+// $((1 << n))) so its shifts are not taken for heredocs.
+func stripArithmetic(instruction string) string {
+	text := []byte(instruction)
+	for start := strings.Index(instruction, "(("); start >= 0; {
+		depth := 0
+		end := len(text)
+		for i := start; i < len(text); i++ {
+			if text[i] == '(' {
+				depth++
+			} else if text[i] == ')' {
+				if depth--; depth == 0 {
+					end = i + 1
+					break
+				}
+			}
+		}
+		for i := start; i < end; i++ {
+			text[i] = ' '
+		}
+		next := strings.Index(string(text[end:]), "((")
+		if next < 0 {
+			break
+		}
+		start = end + next
+	}
+	return string(text)
+}
+
 // ExtractRunCommandsFromDockerfile
-func ExtractRunCommandsFromDockerfile(data string) ([]dockerfile.Command, error) {
+func ExtractRunCommandsFromDockerfile(data string) ([]Command, error) {
 	commandList, err := ExtractAllCommandsFromDockerfile(data)
 	if err != nil {
 		return nil, err
 	}
 
-	var commands []dockerfile.Command
+	var commands []Command
 
 	// Collect all commands in the Dockerfile
 	for _, cmd := range commandList {
@@ -30,3 +30,95 @@ func TestParser(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+var sampleHeredocDockerfile = `# syntax=docker/dockerfile:1.4
+FROM debian:bullseye
+RUN <<EOF
+mkdir -p /opt/app
+touch /opt/app/ready
+EOF
+RUN python3 <<EOF
+print("hello")
+EOF
+RUN <<EOF
+#!/usr/bin/env python3
+import os
+os.remove("/etc/issue")
+EOF
+COPY <<EOF /etc/app.conf
+listen=8080
+EOF
+COPY <<-"first.txt" <<second.txt /opt/app/
+	first
+	first.txt
+second
+second.txt
+RUN cat <<EOF > /opt/app/motd && chmod 644 /opt/app/motd
+welcome
+EOF
+`
+
+func TestParserHeredoc(t *testing.T) {
+	commandList, err := ExtractAllCommandsFromDockerfile(sampleHeredocDockerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commandList) != 7 {
+		t.Fatalf("expected 7 instructions, got %d", len(commandList))
+	}
+
+	run := commandList[1]
+	if len(run.Heredocs) != 1 || run.Heredocs[0].Content != "mkdir -p /opt/app\ntouch /opt/app/ready\n" {
+		t.Errorf("unexpected heredocs %v", run.Heredocs)
+	}
+	if run.StartLine != 3 || run.EndLine != 6 {
+		t.Errorf("unexpected lines %d-%d", run.StartLine, run.EndLine)
+	}
+
+	copyCmd := commandList[5]
+	if len(copyCmd.Heredocs) != 2 {
+		t.Fatalf("expected 2 heredocs, got %d", len(copyCmd.Heredocs))
+	}
+	first, second := copyCmd.Heredocs[0], copyCmd.Heredocs[1]
+	if first.Name != "first.txt" || first.Content != "first\n" || first.Expand || !first.Chomp {
+		t.Errorf("unexpected heredoc %v", first)
+	}
+	if second.Name != "second.txt" || second.Content != "second\n" || !second.Expand || second.Chomp {
+		t.Errorf("unexpected heredoc %v", second)
+	}
+	if commandList[6].StartLine != 23 {
+		t.Errorf("unexpected start line %d", commandList[6].StartLine)
+	}
+}
+
+func TestParserHeredocEdgeCases(t *testing.T) {
+	tests := []struct {
+		name, dockerfile string
+		instructions     int
+		heredoc          string
+	}{
+		{"arithmetic shift", "FROM alpine\nRUN echo $((1<<2)) $((x << y))\nRUN mkdir /a\nWORKDIR /a\n", 4, ""},
+		{"crlf", "FROM alpine\r\nRUN <<EOF\r\nmkdir /a\r\nEOF\r\nWORKDIR /a\r\n", 3, "mkdir /a\n"},
+		{"no terminator", "FROM alpine\nRUN cat <<EOF\nRUN mkdir /a\nWORKDIR /a\n", 4, ""},
+	}
+	for _, test := range tests {
+		commandList, err := ExtractAllCommandsFromDockerfile(test.dockerfile)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(commandList) != test.instructions {
+			t.Errorf("%s: expected %d instructions, got %d", test.name, test.instructions, len(commandList))
+			continue
+		}
+		heredocs := commandList[1].Heredocs
+		if test.heredoc == "" && len(heredocs) != 0 {
+			t.Errorf("%s: unexpected heredocs %v", test.name, heredocs)
+		}
+		if test.heredoc != "" && (len(heredocs) != 1 || heredocs[0].Content != test.heredoc) {
+			t.Errorf("%s: unexpected heredocs %v", test.name, heredocs)
+		}
+		if last := commandList[len(commandList)-1]; last.Cmd != "workdir" {
+			t.Errorf("%s: expected the last instruction to be WORKDIR, got %s", test.name, last.Cmd)
+		}
+	}
+}
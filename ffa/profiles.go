@@ -0,0 +1,179 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// bundledProfiles holds the profiles of common base images.
+//
+//go:embed profiles.yaml
+var bundledProfiles []byte
+
+// ImageProfile describes the filesystem of base images.
+type ImageProfile struct {
+	// Images holds the patterns of the image names the profile applies to
+	// (ex: "alpine:*"). Images without a tag are matched as ":latest".
+	Images []string `yaml:"images"`
+
+	Directories []string `yaml:"directories"`
+	Files       []string `yaml:"files"`
+
+	// Binaries holds the programs in the PATH of the image. Files in a bin or
+	// sbin directory are binaries as well.
+	Binaries []string `yaml:"binaries"`
+}
+
+// ProfileRegistry holds the base image profiles used to translate FROM.
+type ProfileRegistry struct {
+	profiles []ImageProfile
+}
+
+// NewProfileRegistry returns a registry with the profiles bundled with the
+// toolkit.
+func NewProfileRegistry() (*ProfileRegistry, error) {
+	r := &ProfileRegistry{}
+	if err := r.Load(bundledProfiles); err != nil {
+		return nil, fmt.Errorf("bundled profiles: %v", err)
+	}
+	return r, nil
+}
+
+// Load adds the profiles of a YAML document to the registry. Profiles loaded
+// later take precedence over the ones already in the registry.
+func (r *ProfileRegistry) Load(data []byte) error {
+	var document struct {
+		Profiles []ImageProfile `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	for _, profile := range document.Profiles {
+		for _, pattern := range profile.Images {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid image pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	r.profiles = append(document.Profiles, r.profiles...)
+	return nil
+}
+
+// LoadFile adds the profiles of a YAML file to the registry.
+func (r *ProfileRegistry) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.Load(data); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// Match returns the first profile matching an image, or nil if the image is
+// unknown.
+func (r *ProfileRegistry) Match(image string) *ImageProfile {
+	if r == nil {
+		return nil
+	}
+	name := normalizeImage(image)
+	for i, profile := range r.profiles {
+		for _, pattern := range profile.Images {
+			if m, _ := path.Match(pattern, name); m {
+				return &r.profiles[i]
+			}
+			// Patterns without a tag match every tag
+			if !strings.Contains(pattern, ":") {
+				if m, _ := path.Match(pattern+":*", name); m {
+					return &r.profiles[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeImage returns the name and tag of an image reference without the
+// Docker Hub registry and digest (ex: "golang:1.10" for
+// "docker.io/library/golang:1.10@sha256:...").
+func normalizeImage(image string) string {
+	name := strings.SplitN(image, "@", 2)[0]
+	name = strings.TrimPrefix(name, "docker.io/")
+	name = strings.TrimPrefix(name, "index.docker.io/")
+	name = strings.TrimPrefix(name, "library/")
+	if name == "scratch" {
+		return name
+	}
+	if !strings.Contains(path.Base(name), ":") {
+		name += ":latest"
+	}
+	return name
+}
+
+// Preamble returns the statements creating the directories and files of the
+// profile. Parent directories are created before their contents.
+func (p *ImageProfile) Preamble() []string {
+	dirs := make(map[string]bool)
+	addParents := func(name string) {
+		for dir := path.Dir(name); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for _, dir := range p.Directories {
+		dirs[path.Clean(dir)] = true
+		addParents(path.Clean(dir))
+	}
+	for _, file := range p.Files {
+		addParents(path.Clean(file))
+	}
+
+	sortedDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Strings(sortedDirs)
+
+	var preamble []string
+	for _, dir := range sortedDirs {
+		preamble = append(preamble, fmt.Sprintf("mkdir %s;", ffaString(dir)))
+	}
+	for _, file := range p.Files {
+		preamble = append(preamble, fmt.Sprintf("touch %s;", ffaString(path.Clean(file))))
+	}
+	return preamble
+}
+
+// BinarySet returns the programs in the PATH of the image.
+func (p *ImageProfile) BinarySet() map[string]bool {
+	binaries := make(map[string]bool)
+	for _, binary := range p.Binaries {
+		binaries[binary] = true
+	}
+	for _, file := range p.Files {
+		if dir := path.Base(path.Dir(file)); dir == "bin" || dir == "sbin" {
+			binaries[path.Base(file)] = true
+		}
+	}
+	return binaries
+}
@@ -0,0 +1,88 @@
+package ffa
+
+import (
+	"strings"
+	"testing"
+)
+
+func getProfileRegistry(t *testing.T) *ProfileRegistry {
+	profiles, err := NewProfileRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profiles
+}
+
+func TestProfileMatch(t *testing.T) {
+	profiles := getProfileRegistry(t)
+	tests := []struct {
+		image  string
+		binary string
+	}{
+		{"golang:1.10.0", "go"},
+		{"golang:1.17-alpine3.14", "apk"},
+		{"docker.io/library/alpine@sha256:e1c082e3d3c45cccac829840a25941e679c25d438cc8412c2fa221cf1a824e6a", "apk"},
+		{"ubuntu", "apt-get"},
+		{"python:3.9-slim", "pip"},
+	}
+	for _, test := range tests {
+		profile := profiles.Match(test.image)
+		if profile == nil {
+			t.Errorf("no profile for %s", test.image)
+			continue
+		}
+		if !profile.BinarySet()[test.binary] {
+			t.Errorf("%s should have %s", test.image, test.binary)
+		}
+	}
+	if profile := profiles.Match("scratch"); profile == nil || len(profile.Preamble()) != 0 {
+		t.Error("scratch should have an empty profile")
+	}
+	if profile := profiles.Match("rodneyxr/app:latest"); profile != nil {
+		t.Errorf("unexpected profile %v", profile.Images)
+	}
+}
+
+func TestProfileLoad(t *testing.T) {
+	profiles := getProfileRegistry(t)
+	err := profiles.Load([]byte(`profiles:
+  - images: ["golang:*", rodneyxr/app]
+    files: [/opt/app/bin/app]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Profiles loaded later take precedence
+	for _, image := range []string{"golang:1.17", "rodneyxr/app"} {
+		profile := profiles.Match(image)
+		if profile == nil || !profile.BinarySet()["app"] {
+			t.Errorf("%s should use the loaded profile", image)
+		}
+	}
+	if err := profiles.Load([]byte(`profiles: [{images: ["[alpine"]}]`)); err == nil {
+		t.Error("invalid pattern should fail to load")
+	}
+}
+
+func TestDockerfileProfiles(t *testing.T) {
+	script, err := TranslateDockerfile(`FROM golang:1.10.0
+RUN go get github.com/rodneyxr/ffatoolkit && gox -h
+`, DockerfileOptions{Profiles: getProfileRegistry(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{
+		"mkdir '/usr';", "mkdir '/usr/local';", "mkdir '/usr/local/go';", "mkdir '/usr/local/go/bin';",
+		"touch '/usr/local/go/bin/go';",
+		"assert(! exists 'gox');",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "'go'") {
+			t.Errorf("unexpected assertion %s", line)
+		}
+	}
+}
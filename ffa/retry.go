@@ -0,0 +1,186 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// secondaryRateLimitDelay is the time waited after hitting a secondary rate
+// limit when GitHub does not say how long to wait.
+const secondaryRateLimitDelay = time.Minute
+
+// Retrier retries GitHub API calls that fail because of a rate limit or a
+// server error. Calls made concurrently share the rate limit, so all of them
+// wait once one of them hits it.
+type Retrier struct {
+	// MaxRetries is the number of times a call is retried before its error
+	// is returned.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry of a server error. The
+	// delay doubles with each retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Sleep waits for the given duration unless the context is done first.
+	// Now returns the current time. Both can be replaced in tests.
+	Sleep func(ctx context.Context, d time.Duration) error
+	Now   func() time.Time
+
+	// resumeAt is the time calls can be made again after a rate limit.
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+// NewRetrier returns a Retrier with the default settings.
+func NewRetrier() *Retrier {
+	return &Retrier{
+		MaxRetries: 5,
+		BaseDelay:  time.Second,
+		MaxDelay:   time.Minute,
+		Sleep:      sleepContext,
+		Now:        time.Now,
+	}
+}
+
+// DefaultRetrier is the Retrier used for every GitHub API call.
+var DefaultRetrier = NewRetrier()
+
+// Do runs a GitHub API call, retrying it until it succeeds, fails with an
+// error that cannot be retried or runs out of retries.
+func (r *Retrier) Do(ctx context.Context, call func() (*github.Response, error)) error {
+	for attempt := 0; ; attempt++ {
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		resp, err := call()
+		if err == nil {
+			return nil
+		}
+		delay, rateLimited, ok := r.delay(resp, err, attempt)
+		if !ok || attempt >= r.MaxRetries {
+			return err
+		}
+		log.Printf("github: %v - retrying in %s", err, delay.Round(time.Second))
+		if rateLimited {
+			// Every call waits for the rate limit
+			r.pause(delay)
+			continue
+		}
+		if err := r.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// pause stops calls from being made for the given duration.
+func (r *Retrier) pause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if resumeAt := r.Now().Add(d); resumeAt.After(r.resumeAt) {
+		r.resumeAt = resumeAt
+	}
+}
+
+// wait waits until calls can be made again after a rate limit.
+func (r *Retrier) wait(ctx context.Context) error {
+	r.mu.Lock()
+	resumeAt := r.resumeAt
+	r.mu.Unlock()
+	if resumeAt.IsZero() || !resumeAt.After(r.Now()) {
+		return nil
+	}
+	if err := r.Sleep(ctx, resumeAt.Sub(r.Now())); err != nil {
+		return err
+	}
+
+	// The pause is over unless it was extended while waiting
+	r.mu.Lock()
+	if r.resumeAt.Equal(resumeAt) {
+		r.resumeAt = time.Time{}
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// delay returns how long to wait before retrying a failed call and whether
+// the call hit a rate limit, or false if the call should not be retried.
+func (r *Retrier) delay(resp *github.Response, err error, attempt int) (time.Duration, bool, bool) {
+	switch e := err.(type) {
+	case *github.RateLimitError:
+		// Wait until the rate limit resets
+		delay := e.Rate.Reset.Time.Sub(r.Now()) + time.Second
+		if delay < time.Second {
+			delay = time.Second
+		}
+		return delay, true, true
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter, true, true
+		}
+		return secondaryRateLimitDelay, true, true
+	case *github.ErrorResponse:
+		if e.Response != nil {
+			resp = &github.Response{Response: e.Response}
+		}
+	}
+	if resp == nil || resp.Response == nil {
+		return 0, false, false
+	}
+
+	switch status := resp.StatusCode; {
+	case status == http.StatusForbidden || status == http.StatusTooManyRequests:
+		// Secondary rate limits that are not recognized as such by the client
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			return 0, false, false
+		}
+		return time.Duration(seconds) * time.Second, true, true
+	case status >= 500:
+		return r.backoff(attempt), false, true
+	}
+	return 0, false, false
+}
+
+// backoff returns the exponential delay of a retry with jitter, which is a
+// random duration between half and all of the delay.
+func (r *Retrier) backoff(attempt int) time.Duration {
+	delay := r.BaseDelay << uint(attempt)
+	if delay > r.MaxDelay || delay <= 0 {
+		delay = r.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleepContext waits for the given duration unless the context is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
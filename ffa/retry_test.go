@@ -0,0 +1,150 @@
+package ffa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// newFakeGitHub starts a fake GitHub API server and returns a client using
+// it along with a retrier that records its delays instead of sleeping.
+func newFakeGitHub(t *testing.T, mux *http.ServeMux) (*github.Client, *Retrier, *[]time.Duration) {
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	var delays []time.Duration
+	retrier := NewRetrier()
+	retrier.Sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	defaultRetrier := DefaultRetrier
+	DefaultRetrier = retrier
+	t.Cleanup(func() { DefaultRetrier = defaultRetrier })
+	return client, retrier, &delays
+}
+
+func TestRetrierRateLimits(t *testing.T) {
+	// The client refuses requests until the rate limit resets, so the reset
+	// time is in the past and the retrier's clock is set back instead
+	reset := time.Now().Add(-time.Minute).Truncate(time.Second)
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/languages", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded for 127.0.0.1."}`)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "You have triggered an abuse detection mechanism.",
+				"documentation_url": "https://developer.github.com/v3/#abuse-rate-limits"}`)
+		case 3:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 4:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, `{"Go": 75, "Shell": 25}`)
+		}
+	})
+	client, retrier, delays := newFakeGitHub(t, mux)
+	retrier.Now = func() time.Time { return reset.Add(-30 * time.Second) }
+
+	repo := Repo{Owner: "rodneyxr", Repo: "ffatoolkit"}
+	if err := LoadLanguages(context.Background(), client, &repo); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Languages) != 2 || repo.Languages[0].Name != "Go" {
+		t.Errorf("unexpected languages %v", repo.Languages)
+	}
+
+	if len(*delays) != 4 {
+		t.Fatalf("expected 4 retries, got %v", *delays)
+	}
+	if (*delays)[0] != 31*time.Second {
+		t.Errorf("expected to wait until the rate limit resets, waited %s", (*delays)[0])
+	}
+	if (*delays)[1] != 7*time.Second {
+		t.Errorf("expected to wait for Retry-After, waited %s", (*delays)[1])
+	}
+	if (*delays)[2] != 3*time.Second {
+		t.Errorf("expected to wait for Retry-After, waited %s", (*delays)[2])
+	}
+	if delay := (*delays)[3]; delay < 4*time.Second || delay > 8*time.Second {
+		t.Errorf("expected a backoff between 4s and 8s, waited %s", delay)
+	}
+}
+
+func TestRetrierErrors(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/rodneyxr/ffatoolkit/languages", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/search/code", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"message": "Validation Failed"}`)
+	})
+	client, retrier, delays := newFakeGitHub(t, mux)
+
+	// Server errors are retried until the retries run out
+	repo := Repo{Owner: "rodneyxr", Repo: "ffatoolkit"}
+	if err := LoadLanguages(context.Background(), client, &repo); err == nil {
+		t.Error("expected an error")
+	}
+	if calls != retrier.MaxRetries+1 {
+		t.Errorf("expected %d calls, got %d", retrier.MaxRetries+1, calls)
+	}
+	for i, delay := range *delays {
+		if delay > retrier.MaxDelay {
+			t.Errorf("retry %d waited %s", i, delay)
+		}
+	}
+
+	// Other errors are returned right away
+	*delays = nil
+	if err := LoadFiles(context.Background(), client, &repo, FetchOptions{}); err == nil {
+		t.Error("expected an error")
+	}
+	if len(*delays) != 0 {
+		t.Errorf("unexpected retries %v", *delays)
+	}
+}
+
+func TestRetrierSharedRateLimit(t *testing.T) {
+	now := time.Now()
+	var delays []time.Duration
+	retrier := NewRetrier()
+	retrier.Now = func() time.Time { return now }
+	retrier.Sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	// A rate limit hit by one call makes the next calls wait once
+	retrier.pause(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := retrier.Do(context.Background(), func() (*github.Response, error) { return nil, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(delays) != 1 || delays[0] != time.Minute {
+		t.Errorf("expected to wait a minute once, waited %v", delays)
+	}
+}
@@ -2,7 +2,9 @@ package ffa
 
 import (
 	"mvdan.cc/sh/v3/syntax"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // literize converts a []*syntax.Word to a []string
@@ -59,3 +61,170 @@ func removeFlagsLit(arguments []string) []string {
 	}
 	return args
 }
+
+// skipOptions strips the leading options from the arguments of a command and
+// returns the remaining operands. withArg lists the options that consume the
+// following argument as their value. Short options taking a value may end a
+// cluster (-Dm 755) or have the value attached (-m755).
+func skipOptions(arguments []string, withArg []string) []string {
+	takesArg := make(map[string]bool)
+	for _, opt := range withArg {
+		takesArg[opt] = true
+	}
+
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		if arg == "--" {
+			return arguments[i+1:]
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return arguments[i:]
+		}
+		if takesArg[arg] {
+			// The value is the next argument
+			i++
+		} else if _, attached := clusterValue(arg, takesArg); !attached {
+			// The last option of the cluster takes the next argument
+			i++
+		}
+		// Otherwise the option is a flag or its value is attached (-uroot, --user=root)
+	}
+	return nil
+}
+
+// clusterValue finds the first option of a cluster of short options (ex:
+// -Dm) that takes a value. Returns the option and true if its value is
+// attached (-m755), or false if the value is the next argument. Clusters
+// without such an option return an empty option and true.
+func clusterValue(arg string, takesArg map[string]bool) (rune, bool) {
+	if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+		return 0, true
+	}
+	for i, c := range arg[1:] {
+		if takesArg["-"+string(c)] {
+			return c, i+2 < len(arg)
+		}
+	}
+	return 0, true
+}
+
+// isAssignment reports whether the argument is a NAME=VALUE variable assignment.
+func isAssignment(arg string) bool {
+	eq := strings.Index(arg, "=")
+	if eq <= 0 {
+		return false
+	}
+	for i, r := range arg[:eq] {
+		if r != '_' && !unicode.IsLetter(r) && !(i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// extractFlagPrefix finds a long option given as either "--flag=value" or
+// "--flag value". Returns the value and whether the flag was found.
+func extractFlagPrefix(command []string, flag string) (string, bool) {
+	for i, s := range command {
+		if s == flag {
+			if i+1 < len(command) {
+				return command[i+1], true
+			}
+			return "", true
+		}
+		if strings.HasPrefix(s, flag+"=") {
+			return strings.TrimPrefix(s, flag+"="), true
+		}
+	}
+	return "", false
+}
+
+// shortFlagValue returns the value of a single letter option taking one,
+// which may end a cluster of short options (-Dt dir) or be attached to it
+// (-tdir). withArg lists the options that take a value.
+func shortFlagValue(command []string, flag rune, withArg []string) (string, bool) {
+	takesArg := make(map[string]bool)
+	for _, opt := range withArg {
+		takesArg[opt] = true
+	}
+	for i := 0; i < len(command); i++ {
+		s := command[i]
+		c, attached := clusterValue(s, takesArg)
+		if c == 0 {
+			continue
+		}
+		if !attached {
+			i++
+		}
+		if c != flag {
+			continue
+		}
+		if attached {
+			return s[strings.IndexRune(s, c)+1:], true
+		}
+		if i < len(command) {
+			return command[i], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// hasShortFlag reports whether the single letter flag is set, either on its
+// own or clustered with other short flags (e.g. -D in -Dm). withArg lists the
+// options that take a value, whose values are not searched for the flag
+// (e.g. -d in -m755 -gdocker).
+func hasShortFlag(command []string, flag rune, withArg ...string) bool {
+	takesArg := make(map[string]bool)
+	for _, opt := range withArg {
+		takesArg[opt] = true
+	}
+	for i := 0; i < len(command); i++ {
+		s := command[i]
+		if !strings.HasPrefix(s, "-") || strings.HasPrefix(s, "--") {
+			continue
+		}
+		for _, c := range s[1:] {
+			if c == flag {
+				return true
+			}
+			if takesArg["-"+string(c)] {
+				break
+			}
+		}
+		if _, attached := clusterValue(s, takesArg); !attached {
+			i++
+		}
+	}
+	return false
+}
+
+// substCall returns the command of a word that consists of a single command
+// substitution, such as $(mktemp -d) or "$(mktemp -d)".
+func substCall(word *syntax.Word) *syntax.CallExpr {
+	if len(word.Parts) != 1 {
+		return nil
+	}
+	part := word.Parts[0]
+	if dq, ok := part.(*syntax.DblQuoted); ok && len(dq.Parts) == 1 {
+		part = dq.Parts[0]
+	}
+	subst, ok := part.(*syntax.CmdSubst)
+	if !ok || len(subst.Stmts) != 1 {
+		return nil
+	}
+	call, ok := subst.Stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return nil
+	}
+	return call
+}
+
+// isPositional reports whether the variable name is a positional parameter.
+func isPositional(name string) bool {
+	if name == "@" || name == "*" || name == "#" {
+		return true
+	}
+	_, err := strconv.Atoi(name)
+	return err == nil && name != "0"
+}
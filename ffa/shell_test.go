@@ -1,6 +1,48 @@
 package ffa
 
+import (
+	"reflect"
+	"testing"
+)
+
 //func TestExtractFlag(t *testing.T) {
 //	command := ["", "", ""]
 //
 //}
+
+func TestSkipOptionsClusters(t *testing.T) {
+	tests := []struct {
+		args, operands []string
+	}{
+		{[]string{"-D", "-m", "755", "app", "/bin/app"}, []string{"app", "/bin/app"}},
+		{[]string{"-Dm", "755", "app", "/bin/app"}, []string{"app", "/bin/app"}},
+		{[]string{"-m755", "app", "/bin/app"}, []string{"app", "/bin/app"}},
+		{[]string{"-Dt", "/opt/bin", "a", "b"}, []string{"a", "b"}},
+		{[]string{"--mode=755", "-D", "app", "/bin/app"}, []string{"app", "/bin/app"}},
+	}
+	for _, test := range tests {
+		operands := skipOptions(test.args, installOptions)
+		if !reflect.DeepEqual(operands, test.operands) {
+			t.Errorf("%v: expected operands %v, got %v", test.args, test.operands, operands)
+		}
+	}
+}
+
+func TestShortFlagClusters(t *testing.T) {
+	options := []string{"-Dm", "755", "-gdocker", "-t", "/opt/bin"}
+	if !hasShortFlag(options, 'D', installOptions...) {
+		t.Error("expected -D to be set")
+	}
+	if hasShortFlag(options, 'd', installOptions...) || hasShortFlag(options, 'o', installOptions...) {
+		t.Error("the values of options should not be read as flags")
+	}
+	if value, ok := shortFlagValue(options, 't', installOptions); !ok || value != "/opt/bin" {
+		t.Errorf("expected -t /opt/bin, got %q", value)
+	}
+	if value, ok := shortFlagValue(options, 'm', installOptions); !ok || value != "755" {
+		t.Errorf("expected -m 755, got %q", value)
+	}
+	if value, ok := shortFlagValue(options, 'g', installOptions); !ok || value != "docker" {
+		t.Errorf("expected -g docker, got %q", value)
+	}
+}
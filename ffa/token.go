@@ -0,0 +1,106 @@
+// Copyright © 2020 Rodney Rodriguez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffa
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UnauthenticatedRateLimit is the number of requests an hour the GitHub API
+// allows without a token.
+const UnauthenticatedRateLimit = 60
+
+// TokenHelp tells how to give a GitHub token.
+const TokenHelp = "use --token or --token-file, set GITHUB_TOKEN or GH_TOKEN, or log in with \"gh auth login\""
+
+// ResolveToken returns the GitHub token to use along with where it was found,
+// looking in order at the given token, the given token file, the GITHUB_TOKEN
+// and GH_TOKEN environment variables and the hosts file of the gh CLI. An
+// empty token is returned if there is none. A hosts file that cannot be read
+// is reported as a warning, since commands may run without a token.
+func ResolveToken(token, tokenFile string) (string, string, error) {
+	if token = strings.TrimSpace(token); token != "" {
+		return token, "--token", nil
+	}
+	if tokenFile != "" {
+		data, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return "", "", fmt.Errorf("error reading the token file: %v", err)
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return "", "", fmt.Errorf("the token file %s is empty", tokenFile)
+		}
+		return token, tokenFile, nil
+	}
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token = strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, name, nil
+		}
+	}
+
+	hostsFile := ghHostsFile()
+	token, err := ghToken(hostsFile)
+	if err != nil {
+		log.Printf("warning: ignoring the gh hosts file %s: %v", hostsFile, err)
+		return "", "", nil
+	}
+	if token != "" {
+		return token, hostsFile, nil
+	}
+	return "", "", nil
+}
+
+// ghHostsFile returns the path of the hosts file the gh CLI stores its
+// tokens in.
+func ghHostsFile() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// ghToken returns the github.com token of a gh CLI hosts file, if any.
+// Tokens kept in the system keyring by newer versions of gh are not found.
+func ghToken(hostsFile string) (string, error) {
+	data, err := ioutil.ReadFile(hostsFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hosts["github.com"].OAuthToken), nil
+}
@@ -0,0 +1,63 @@
+package ffa
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token.txt")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	hosts := "github.com:\n    user: octocat\n    oauth_token: gh-token\n    git_protocol: https\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_CONFIG_DIR", dir)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+
+	// Each source is used when the ones before it are missing
+	tests := []struct {
+		token, tokenFile, githubToken, ghToken string
+		expected, source                       string
+	}{
+		{"flag-token", tokenFile, "env-token", "", "flag-token", "--token"},
+		{"", tokenFile, "env-token", "", "file-token", tokenFile},
+		{"", "", "env-token", "other-token", "env-token", "GITHUB_TOKEN"},
+		{"", "", "", "other-token", "other-token", "GH_TOKEN"},
+		{"", "", "", "", "gh-token", filepath.Join(dir, "hosts.yml")},
+	}
+	for _, test := range tests {
+		t.Setenv("GITHUB_TOKEN", test.githubToken)
+		t.Setenv("GH_TOKEN", test.ghToken)
+		token, source, err := ResolveToken(test.token, test.tokenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != test.expected || source != test.source {
+			t.Errorf("expected %s from %s, got %s from %s", test.expected, test.source, token, source)
+		}
+	}
+
+	// No token is found without a hosts file
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	if token, _, err := ResolveToken("", ""); err != nil || token != "" {
+		t.Errorf("expected no token, got %q (%v)", token, err)
+	}
+	if _, _, err := ResolveToken("", filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("expected an error for a missing token file")
+	}
+
+	// A malformed hosts file is ignored
+	if err := ioutil.WriteFile(filepath.Join(dir, "hosts.yml"), []byte("github.com: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GH_CONFIG_DIR", dir)
+	if token, _, err := ResolveToken("", ""); err != nil || token != "" {
+		t.Errorf("expected no token, got %q (%v)", token, err)
+	}
+}
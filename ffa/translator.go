@@ -1,12 +1,11 @@
 package ffa
 
 // FIXME: if [... will be seen as a command and assert that '[' does not exist
-// FIXME: quoted values do not appear in translation. ex: touch 'a' will translate to touch ''
 
 import (
 	"fmt"
 	"log"
-	"path/filepath"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,36 +13,6 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
-func TranslateDockerfile(data string) ([]string, error) {
-	var ffaScript []string
-	// Parse the Dockerfile
-	commandList, err := ExtractAllCommandsFromDockerfile(data)
-	if err != nil {
-		log.Print(err)
-	}
-
-	for _, cmd := range commandList {
-		switch cmd.Cmd {
-		case "run":
-			results, err := TranslateShellScript(strings.Join(cmd.Value, " "))
-			if err != nil {
-				return ffaScript, err
-			}
-			ffaScript = append(ffaScript, results...)
-			break
-		case "workdir":
-			ffaScript = append(ffaScript, "cd '"+cmd.Value[0]+"';")
-			break
-		case "copy":
-			if len(cmd.Value) == 2 {
-				ffaScript = append(ffaScript, "cp '"+cmd.Value[0]+"' '"+cmd.Value[1]+"';")
-			}
-			break
-		}
-	}
-	return ffaScript, nil
-}
-
 type stack []syntax.Node
 
 func (s stack) Push(node syntax.Node) stack {
@@ -85,6 +54,13 @@ func isScope(node syntax.Node, data string) bool {
 	return false
 }
 
+func isPipe(node syntax.Node) bool {
+	if x, ok := node.(*syntax.BinaryCmd); ok {
+		return x.Op == syntax.Pipe || x.Op == syntax.PipeAll
+	}
+	return false
+}
+
 func isElseScope(node syntax.Node) bool {
 	var empty syntax.Pos
 	switch x := node.(type) {
@@ -96,28 +72,277 @@ func isElseScope(node syntax.Node) bool {
 	return false
 }
 
+// shellTranslator holds the state used while translating a shell script.
+type shellTranslator struct {
+	ffaList    []string
+	varbank    map[string]string
+	varCounter int
+
+	// env holds the known values of environment variables, such as those set
+	// by ENV and ARG instructions in a Dockerfile.
+	env map[string]string
+
+	// pipeDepth is the number of pipelines enclosing the current command and
+	// stdin holds the FFA variable for the paths piped into it, if known.
+	pipeDepth int
+	stdin     string
+
+	// binaries holds the programs known to be in the PATH, such as those of
+	// the base image of a Dockerfile.
+	binaries map[string]bool
+}
+
+func newShellTranslator() *shellTranslator {
+	return &shellTranslator{varbank: make(map[string]string)}
+}
+
+// emit appends a formatted statement to the FFA script at the current scope.
+func (t *shellTranslator) emit(format string, a ...interface{}) {
+	statement := strings.ReplaceAll(fmt.Sprintf(format, a...), ffaVarPrefix, "$x")
+	t.ffaList = appendFFAList(t.ffaList, statement)
+}
+
+// newVar returns a fresh FFA variable name.
+func (t *shellTranslator) newVar() string {
+	ffaVar := ffaVarPrefix + strconv.Itoa(t.varCounter)
+	t.varCounter++
+	return ffaVar
+}
+
+// warn records a diagnostic for a statement that could not be fully
+// represented in FFAL. Diagnostics are emitted as comments in the script.
+func (t *shellTranslator) warn(format string, a ...interface{}) {
+	t.emit("// warning: "+format, a...)
+}
+
+// newInputVar returns a fresh FFA variable bound to INPUT.
+func (t *shellTranslator) newInputVar() string {
+	ffaVar := t.newVar()
+	t.emit("%s = INPUT;", ffaVar)
+	return ffaVar
+}
+
+// bindInput assigns INPUT to the shell variable name.
+func (t *shellTranslator) bindInput(name string) string {
+	ffaVar, ok := t.varbank[name]
+	if !ok {
+		ffaVar = t.newVar()
+		t.varbank[name] = ffaVar
+	}
+	t.emit("%s = INPUT;", ffaVar)
+	return ffaVar
+}
+
+// lookupVar returns the value of a shell variable, which is either the FFA
+// variable it was assigned to or the known value of an environment variable.
+// Positional parameters are controlled by the user and are bound to INPUT,
+// while the rest of the environment (ex: $HOME) is left as a reference.
+func (t *shellTranslator) lookupVar(name string) string {
+	if ffaVar, ok := t.varbank[name]; ok {
+		return ffaVar
+	}
+	if value, ok := t.env[name]; ok {
+		return value
+	}
+	if isPositional(name) {
+		return t.bindInput(name)
+	}
+	return "$" + name
+}
+
+// bindItems assigns the items of a for loop to the shell variable name, one
+// of them on each iteration. Loops without items go over the positional
+// parameters, which are bound to INPUT.
+func (t *shellTranslator) bindItems(name string, items []string) {
+	if len(items) == 0 {
+		t.bindInput(name)
+		return
+	}
+	ffaVar, ok := t.varbank[name]
+	if !ok {
+		ffaVar = t.newVar()
+		t.varbank[name] = ffaVar
+	}
+	if len(items) == 1 {
+		t.emit("%s = %s;", ffaVar, ffaString(items[0]))
+		return
+	}
+	for i, item := range items {
+		switch i {
+		case 0:
+			t.emit("if (other) {")
+		case len(items) - 1:
+			t.emit("} else {")
+		default:
+			t.emit("} else if (other) {")
+		}
+		scopeCounter++
+		t.emit("%s = %s;", ffaVar, ffaString(item))
+		scopeCounter--
+	}
+	t.emit("}")
+}
+
+// resolveItem resolves an item of a for loop. Globs match files that are not
+// known and are bound to INPUT.
+func (t *shellTranslator) resolveItem(word *syntax.Word) string {
+	for _, part := range word.Parts {
+		if lit, ok := part.(*syntax.Lit); ok && strings.ContainsAny(lit.Value, "*?[") {
+			return t.newInputVar()
+		}
+	}
+	return t.resolveWord(word)
+}
+
+// resolveWord converts a shell word into an argument string. Quoted parts are
+// unquoted, and expansions are replaced with the FFA variables holding their
+// values, which are later turned into expressions by ffaString.
+func (t *shellTranslator) resolveWord(word *syntax.Word) string {
+	return t.resolveParts(word.Parts)
+}
+
+// resolveWords resolves each word of a command.
+func (t *shellTranslator) resolveWords(words []*syntax.Word) []string {
+	var args []string
+	for _, word := range words {
+		args = append(args, t.resolveWord(word))
+	}
+	return args
+}
+
+func (t *shellTranslator) resolveParts(parts []syntax.WordPart) string {
+	var sb strings.Builder
+	for _, part := range parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(x.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(x.Value)
+		case *syntax.DblQuoted:
+			sb.WriteString(t.resolveParts(x.Parts))
+		case *syntax.ParamExp:
+			sb.WriteString(t.resolveParam(x))
+		default:
+			// Command substitutions, arithmetic and globs are unknown
+			sb.WriteString(t.newInputVar())
+		}
+	}
+	return sb.String()
+}
+
+// resolveParam resolves a parameter expansion such as $1, ${HOME} or
+// ${DIR:-/tmp}.
+func (t *shellTranslator) resolveParam(x *syntax.ParamExp) string {
+	if x.Param == nil || x.Length || x.Width || x.Excl || x.Index != nil || x.Slice != nil || x.Repl != nil {
+		return t.newInputVar()
+	}
+	name := x.Param.Value
+	if x.Exp != nil {
+		// Positional parameters are always set by the user
+		_, assigned := t.varbank[name]
+		_, known := t.env[name]
+		bound := assigned || known || isPositional(name)
+		word := func() string {
+			if x.Exp.Word == nil {
+				return ""
+			}
+			return t.resolveWord(x.Exp.Word)
+		}
+		switch x.Exp.Op {
+		case syntax.DefaultUnset, syntax.DefaultUnsetOrNull:
+			// Unassigned variables use the default value
+			if bound {
+				return t.lookupVar(name)
+			}
+			return word()
+		case syntax.AssignUnset, syntax.AssignUnsetOrNull:
+			// Unassigned variables are assigned the default value, which
+			// later expansions use
+			if bound {
+				return t.lookupVar(name)
+			}
+			ffaVar := t.newVar()
+			t.varbank[name] = ffaVar
+			t.emit("%s = %s;", ffaVar, ffaString(word()))
+			return ffaVar
+		case syntax.AlternateUnset, syntax.AlternateUnsetOrNull:
+			// Assigned variables are replaced with the alternate value
+			if bound {
+				return word()
+			}
+			return ""
+		case syntax.ErrorUnset, syntax.ErrorUnsetOrNull:
+		default:
+			// Trimming and case conversion produce unknown values
+			return t.newInputVar()
+		}
+	}
+	return t.lookupVar(name)
+}
+
+// ffaVarPrefix starts the FFA variables embedded in translated arguments until
+// they are written out as $x0, $x1, ... It cannot appear in shell words, so
+// literal text such as '$x5' is never mistaken for a variable.
+const ffaVarPrefix = "\x00x"
+
+// ffaVarRegexp matches FFA variable references embedded in translated arguments.
+var ffaVarRegexp = regexp.MustCompile(ffaVarPrefix + `[0-9]+`)
+
+// ffaString converts an argument into an FFA string expression. Arguments may
+// embed FFA variables (e.g. "$x0/bin"), which are concatenated with the quoted
+// literal parts (e.g. "$x0 + '/bin'").
+func ffaString(s string) string {
+	var parts []string
+	last := 0
+	for _, loc := range ffaVarRegexp.FindAllStringIndex(s, -1) {
+		if loc[0] > last {
+			parts = append(parts, "'"+s[last:loc[0]]+"'")
+		}
+		parts = append(parts, "$x"+s[loc[0]+len(ffaVarPrefix):loc[1]])
+		last = loc[1]
+	}
+	if last < len(s) || len(parts) == 0 {
+		parts = append(parts, "'"+s[last:]+"'")
+	}
+	return strings.Join(parts, " + ")
+}
+
 func TranslateShellScript(data string) ([]string, error) {
-	var ffaList []string
+	t := newShellTranslator()
+	if err := t.translateScript(data); err != nil {
+		return nil, err
+	}
+	return t.ffaList, nil
+}
+
+// translateScript parses a shell script and appends its translation to the
+// FFA script. Shell variables do not carry over from previous scripts.
+func (t *shellTranslator) translateScript(data string) error {
 	in := strings.NewReader(data)
 	parser := syntax.NewParser()
 	f, err := parser.Parse(in, "")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	ffaVarCounter := 0
 	scopeCounter = 0
 	nodes = stack{}
-	var varbank = make(map[string]string)
+	t.varbank = make(map[string]string)
 
 	syntax.Walk(f, func(node syntax.Node) bool {
 		if node == nil {
 			var x syntax.Node
 			nodes, x = nodes.Pop()
+			if isPipe(x) {
+				t.pipeDepth--
+				if t.pipeDepth == 0 {
+					t.stdin = ""
+				}
+			}
 			if isElseScope(x) {
 				return false
 			} else if isScope(x, data) {
 				scopeCounter--
-				ffaList = appendFFAList(ffaList, "}")
+				t.emit("}")
 				return false
 			}
 		} else {
@@ -125,11 +350,9 @@ func TranslateShellScript(data string) ([]string, error) {
 			case *syntax.Assign:
 				// Check if varname is in bank
 				if x.Name != nil {
-					ffaVar, ok := varbank[x.Name.Value]
+					ffaVar, ok := t.varbank[x.Name.Value]
 					if !ok {
-						ffaVar = "$x" + strconv.Itoa(ffaVarCounter)
-						// increment x? variable name
-						ffaVarCounter++
+						ffaVar = t.newVar()
 					}
 
 					// If RHS is unknown use 'INPUT'
@@ -137,12 +360,19 @@ func TranslateShellScript(data string) ([]string, error) {
 					if rhs == nil || len(rhs.Parts) == 0 {
 						return false
 					}
-					if _, ok = rhs.Parts[0].(*syntax.Lit); !ok {
-						// If RHS is not of type Lit, then we use INPUT
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("%s = INPUT;", ffaVar))
-					} else {
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("%s = '%s';", ffaVar, rhs.Lit()))
+					t.varbank[x.Name.Value] = ffaVar
+
+					// Temporary paths are bound to the assigned variable
+					if call := substCall(rhs); call != nil {
+						if call.Args[0].Lit() == "mktemp" {
+							t.translateMktemp(t.resolveWords(call.Args), ffaVar)
+							return false
+						}
+						// The output of other commands is unknown
+						t.emit("%s = INPUT;", ffaVar)
+						break
 					}
+					t.emit("%s = %s;", ffaVar, ffaString(t.resolveWord(rhs)))
 				}
 				break
 			case *syntax.CallExpr:
@@ -150,157 +380,48 @@ func TranslateShellScript(data string) ([]string, error) {
 				if len(x.Args) == 0 {
 					break
 				}
-
-				// We only handle most common commands
-				cmd := x.Args[0].Lit()
-				switch cmd {
-				case "read":
-
-				case "touch":
-					// Create a touch statement for each argument
-					for _, s := range x.Args[1:] {
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("touch '%s';", s.Lit()))
-					}
-					break
-				case "mkdir":
-					args := removeFlags(x.Args)
-					for _, s := range args[1:] {
-						// TODO: handle arguments with variables
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("mkdir '%s';", s))
-					}
-					break
-				case "rm":
-					fallthrough
-				case "rmdir":
-					// TODO: check for flags
-					// TODO: check for -r and use rmr
-					args := removeFlags(x.Args)
-					for _, s := range args[1:] {
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("rmr '%s';", s))
-					}
-					break
-				case "cp":
-					args := removeFlags(x.Args)
-					arg1, arg2 := args[1], args[2]
-					ffaList = appendFFAList(ffaList, fmt.Sprintf("cp '%s' '%s';", arg1, arg2))
-					break
-				case "mv":
-					args := removeFlags(x.Args)
-					arg1, arg2 := args[1], args[2]
-					ffaList = appendFFAList(ffaList, fmt.Sprintf("cp '%s' '%s';", arg1, arg2))
-					ffaList = appendFFAList(ffaList, fmt.Sprintf("rmr '%s';", arg1))
-					break
-				case "git":
-					// TODO: handle git rm
-					arg1 := x.Args[1].Lit()
-					if arg1 == "clone" {
-						dirname := filepath.Base(x.Args[2].Lit())
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("mkdir '%s';", dirname))
-					}
-					break
-				case "cd":
-					if len(x.Args) == 1 {
-						// Typically 'cd' with no args with go to user's home directory...
-						ffaList = appendFFAList(ffaList, "cd '/';")
-					} else {
-						arg1 := x.Args[1].Lit()
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("cd '%s';", arg1))
-					}
-					break
-				case "wget":
-					command := literize(x.Args)
-					command, args := extractFlag(command, "-O", 1)
-					if args != nil {
-						// if -O is present, touch full path
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("touch '%s';", args[1]))
-					} else {
-						command = removeFlagsLit(command)
-						// if -O is not present, we don't always know what the filename will be
-						//ffaList = appendFFAList(ffaList, fmt.Sprintf("touch '%s';", filepath.Base(command[1])))
-					}
-					break
-				case "curl":
-					command := literize(x.Args)
-					command, args := extractFlag(command, "-O", 1)
-					if args != nil {
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("touch '%s';", args[1]))
-					}
-					break
-				case "chmod":
-					command := literize(x.Args)
-					command = removeFlagsLit(command)
-					if len(command) >= 3 {
-						for _, filename := range command[2:] {
-							ffaList = appendFFAList(ffaList, fmt.Sprintf("assert(exists '%s');", filename))
-						}
-					}
-					break
-				case "file":
-					fallthrough
-				case "source":
-					fallthrough
-				case "python":
-					fallthrough
-				case "python2":
-					fallthrough
-				case "python3":
-					command := literize(x.Args)
-					command = removeFlagsLit(command)
-					if len(command) >= 2 {
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("assert(exists '%s');", command[1]))
-					}
-					break
-				case "tar":
-					// TODO: handle tar
-					break
-				case "set":
-					// TODO: handle variables
-					break
-				case "ln":
-					// TODO: handle symlinks
-					break
-				case "export":
-					// TODO: handle variables
-					break
-				default:
-					// if strings.HasPrefix("./")
-					if m, err := regexp.MatchString(`^\.*?/`, cmd); err != nil {
-						log.Fatal(err)
-					} else if m {
-						// Assert that unknown scripts/binaries exists if relative or absolute path is invoked
-						ffaList = appendFFAList(ffaList, fmt.Sprintf("assert(exists '%s');", cmd))
-					} else {
-						// Ignore if conditions
-						if len(cmd) > 0 && cmd[0] != '[' {
-							// Assert that the binary does not exist locally
-							ffaList = appendFFAList(ffaList, fmt.Sprintf("assert(! exists '%s');", cmd))
-						}
-					}
-				}
+				t.translateCommand(t.resolveWords(x.Args))
 			case *syntax.IfClause:
 				var empty syntax.Pos
 
 				// Condition to check if IfClause node is an Else statement
 				if x.ThenPos == empty && x.FiPos != empty {
 					scopeCounter--
-					ffaList = appendFFAList(ffaList, "} else {")
+					t.emit("} else {")
 					scopeCounter++
 					// Condition to check if IfClause node is an elif statement
 				} else if x.ThenPos != empty && string(data[x.Pos().Offset()]) == "e" {
 					scopeCounter--
-					ffaList = appendFFAList(ffaList, "} else if (other) {")
+					t.emit("} else if (other) {")
 					scopeCounter++
 				} else {
-					ffaList = appendFFAList(ffaList, "if (other) {")
+					t.emit("if (other) {")
 				}
 			case *syntax.WhileClause:
-				ffaList = appendFFAList(ffaList, "while (other) {")
+				t.emit("while (other) {")
 			case *syntax.ForClause:
-				ffaList = appendFFAList(ffaList, "while (other) {")
+				// The loop variable takes each of the items, which are
+				// resolved before the loop
+				iter, ok := x.Loop.(*syntax.WordIter)
+				var items []string
+				if ok {
+					for _, item := range iter.Items {
+						items = append(items, t.resolveItem(item))
+					}
+				}
+				t.emit("while (other) {")
+				if ok {
+					scopeCounter++
+					t.bindItems(iter.Name.Value, items)
+					scopeCounter--
+				}
 			case *syntax.CaseClause:
 			case *syntax.Block:
 			case *syntax.Subshell:
 			case *syntax.BinaryCmd:
+				if isPipe(x) {
+					t.pipeDepth++
+				}
 			case *syntax.FuncDecl:
 			case *syntax.ArithmCmd:
 			case *syntax.TestClause:
@@ -317,6 +438,677 @@ func TranslateShellScript(data string) ([]string, error) {
 		}
 		return true
 	})
+	return nil
+}
+
+// translateCommand translates a single command invocation. The first element
+// of command is the name of the command followed by its arguments.
+func (t *shellTranslator) translateCommand(command []string) {
+	if len(command) == 0 {
+		return
+	}
+
+	// We only handle most common commands
+	cmd := command[0]
+	switch cmd {
+	case "read":
+		// Each variable is assigned a value read from standard input
+		names := skipOptions(command[1:], []string{"-a", "-d", "-i", "-n", "-N", "-p", "-t", "-u"})
+		if _, flags := extractFlag(command[:len(command)-len(names)], "-a", 1); len(flags) == 2 {
+			names = append(names, flags[1])
+		}
+		if len(names) == 0 {
+			names = []string{"REPLY"}
+		}
+		for _, name := range names {
+			t.bindInput(name)
+		}
+	case "getopts":
+		// The option name and its argument come from the positional parameters
+		if len(command) >= 3 {
+			t.bindInput(command[2])
+			t.bindInput("OPTARG")
+		}
+	case "shift":
+		// Every positional parameter takes a new unknown value
+		for name := range t.varbank {
+			if isPositional(name) {
+				delete(t.varbank, name)
+			}
+		}
+	case "sudo", "env", "nohup", "exec", "command", "nice", "stdbuf", "time":
+		// Wrappers run the wrapped command as is
+		t.translateCommand(stripWrapper(command))
+	case "xargs":
+		t.translateXargs(command)
+	case "find":
+		t.translateFind(command)
+	case "touch":
+		// Create a touch statement for each argument
+		for _, s := range command[1:] {
+			t.emit("touch %s;", ffaString(s))
+		}
+	case "mkdir":
+		args := removeFlagsLit(command)
+		for _, s := range args[1:] {
+			// TODO: handle arguments with variables
+			t.emit("mkdir %s;", ffaString(s))
+		}
+	case "rm", "rmdir":
+		// TODO: check for flags
+		// TODO: check for -r and use rmr
+		args := removeFlagsLit(command)
+		for _, s := range args[1:] {
+			t.emit("rmr %s;", ffaString(s))
+		}
+	case "cp":
+		args := removeFlagsLit(command)
+		if len(args) < 3 {
+			break
+		}
+		t.emit("cp %s %s;", ffaString(args[1]), ffaString(args[2]))
+	case "mv":
+		args := removeFlagsLit(command)
+		if len(args) < 3 {
+			break
+		}
+		t.emit("cp %s %s;", ffaString(args[1]), ffaString(args[2]))
+		t.emit("rmr %s;", ffaString(args[1]))
+	case "git":
+		t.translateGit(command)
+	case "cd":
+		if len(command) == 1 {
+			// Typically 'cd' with no args with go to user's home directory...
+			t.emit("cd '/';")
+		} else {
+			t.emit("cd %s;", ffaString(command[1]))
+		}
+	case "wget":
+		command, args := extractFlag(command, "-O", 1)
+		if args != nil {
+			// if -O is present, touch full path
+			t.emit("touch %s;", ffaString(args[1]))
+		} else {
+			command = removeFlagsLit(command)
+			// if -O is not present, we don't always know what the filename will be
+			//t.emit("touch '%s';", filepath.Base(command[1]))
+		}
+	case "curl":
+		_, args := extractFlag(command, "-O", 1)
+		if args != nil {
+			t.emit("touch %s;", ffaString(args[1]))
+		}
+	case "chmod":
+		command = removeFlagsLit(command)
+		if len(command) >= 3 {
+			for _, filename := range command[2:] {
+				t.emit("assert(exists %s);", ffaString(filename))
+			}
+		}
+	case "chown", "chgrp":
+		args := removeFlagsLit(command)
+		// The owner is omitted when it is taken from a reference file
+		files := args[1:]
+		if _, ref := extractFlagPrefix(command, "--reference"); !ref && len(files) > 0 {
+			files = files[1:]
+		}
+		for _, filename := range files {
+			t.emit("assert(exists %s);", ffaString(filename))
+		}
+	case "sed":
+		t.translateSed(command)
+	case "perl":
+		t.translatePerl(command)
+	case "install":
+		t.translateInstall(command)
+	case "truncate":
+		args := skipOptions(command[1:], []string{"-s", "--size", "-r", "--reference"})
+		if _, noCreate := extractFlagPrefix(command, "--no-create"); noCreate || hasShortFlag(command, 'c') {
+			for _, filename := range args {
+				t.emit("assert(exists %s);", ffaString(filename))
+			}
+			break
+		}
+		for _, filename := range args {
+			t.emit("touch %s;", ffaString(filename))
+		}
+	case "dd":
+		for _, arg := range command[1:] {
+			if strings.HasPrefix(arg, "if=") {
+				t.emit("assert(exists %s);", ffaString(strings.TrimPrefix(arg, "if=")))
+			}
+		}
+		for _, arg := range command[1:] {
+			if strings.HasPrefix(arg, "of=") {
+				t.emit("touch %s;", ffaString(strings.TrimPrefix(arg, "of=")))
+			}
+		}
+	case "mktemp":
+		t.translateMktemp(command, t.newVar())
+	case "file", "source", "python", "python2", "python3":
+		command = removeFlagsLit(command)
+		if len(command) >= 2 {
+			t.emit("assert(exists %s);", ffaString(command[1]))
+		}
+	case "tar":
+		// TODO: handle tar
+	case "set":
+		// set -- assigns the positional parameters
+		for i, arg := range command {
+			if arg == "--" {
+				for n, value := range command[i+1:] {
+					ffaVar := t.newVar()
+					t.emit("%s = %s;", ffaVar, ffaString(value))
+					t.varbank[strconv.Itoa(n+1)] = ffaVar
+				}
+				break
+			}
+		}
+	case "ln":
+		// TODO: handle symlinks
+	case "export":
+		// TODO: handle variables
+	default:
+		// if strings.HasPrefix("./")
+		if m, err := regexp.MatchString(`^\.*?/`, cmd); err != nil {
+			log.Fatal(err)
+		} else if m {
+			// Assert that unknown scripts/binaries exists if relative or absolute path is invoked
+			t.emit("assert(exists %s);", ffaString(cmd))
+		} else {
+			// Ignore if conditions
+			if len(cmd) > 0 && cmd[0] != '[' && !t.binaries[cmd] {
+				// Assert that the binary does not exist locally
+				t.emit("assert(! exists %s);", ffaString(cmd))
+			}
+		}
+	}
+}
+
+// wrapperOptions lists the options that take an argument for each command
+// that runs another command.
+var wrapperOptions = map[string][]string{
+	"sudo": {"-u", "--user", "-g", "--group", "-h", "--host", "-p", "--prompt", "-C", "--close-from",
+		"-D", "--chdir", "-R", "--chroot", "-r", "--role", "-t", "--type", "-T", "--command-timeout",
+		"-U", "--other-user"},
+	"env":     {"-u", "--unset", "-C", "--chdir", "-S", "--split-string"},
+	"nohup":   {},
+	"exec":    {"-a"},
+	"command": {},
+	"nice":    {"-n", "--adjustment"},
+	"stdbuf":  {"-i", "--input", "-o", "--output", "-e", "--error"},
+	"time":    {"-f", "--format", "-o", "--output"},
+}
+
+// stripWrapper removes a wrapper command along with its own options and
+// environment assignments, returning the wrapped command. An empty command is
+// returned if the wrapper does not run a command.
+func stripWrapper(command []string) []string {
+	name := command[0]
+	args := skipOptions(command[1:], wrapperOptions[name])
+
+	// 'command -v' and 'command -V' only describe the command
+	if name == "command" {
+		for _, opt := range command[1 : len(command)-len(args)] {
+			if strings.HasPrefix(opt, "-") && strings.ContainsAny(opt, "vV") {
+				return nil
+			}
+		}
+	}
+
+	// sudo and env accept variable assignments before the command
+	if name == "sudo" || name == "env" {
+		for len(args) > 0 && isAssignment(args[0]) {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+// xargsOptions lists the options of xargs that take an argument.
+var xargsOptions = []string{"-a", "--arg-file", "-d", "--delimiter", "-E", "-I", "-L", "-n", "--max-args",
+	"-P", "--max-procs", "-s", "--max-chars", "--process-slot-var"}
+
+// translateXargs translates the command run by xargs. Since the arguments are
+// read from standard input, the command is applied to an INPUT path unless the
+// paths were piped from find.
+func (t *shellTranslator) translateXargs(command []string) {
+	args := skipOptions(command[1:], xargsOptions)
+	if len(args) == 0 {
+		// xargs runs echo by default
+		return
+	}
+
+	// Find the replacement string if one was given (-I {}, -I{}, -i, -i{} or
+	// --replace={})
+	replace := ""
+	options := command[1 : len(command)-len(args)]
+	for i := 0; i < len(options); i++ {
+		opt := options[i]
+		switch {
+		case opt == "-I" && i+1 < len(options):
+			i++
+			replace = options[i]
+		case strings.HasPrefix(opt, "-I"):
+			replace = strings.TrimPrefix(opt, "-I")
+		case strings.HasPrefix(opt, "-i"):
+			replace = strings.TrimPrefix(opt, "-i")
+		case strings.HasPrefix(opt, "--replace"):
+			replace = strings.TrimPrefix(strings.TrimPrefix(opt, "--replace"), "=")
+		case containsString(xargsOptions, opt):
+			// Skip the value of the option
+			i++
+			continue
+		default:
+			continue
+		}
+		if replace == "" {
+			replace = "{}"
+		}
+	}
+
+	// Paths piped from find are known, anything else is unknown input
+	input := t.stdin
+	if input == "" {
+		input = t.newInputVar()
+	}
+	if replace != "" {
+		wrapped := make([]string, len(args))
+		for i, arg := range args {
+			wrapped[i] = strings.ReplaceAll(arg, replace, input)
+		}
+		t.translateCommand(wrapped)
+	} else {
+		t.translateCommand(append(args, input))
+	}
+}
+
+// findOperators are the find expression tokens that make the matched set of
+// paths depend on more than a filter of the paths under the root.
+var findOperators = map[string]bool{
+	"-o": true, "-or": true, "!": true, "-not": true, "(": true, "\\(": true, "-prune": true,
+}
+
+// translateFind translates find commands that act on the paths they match.
+// The matched paths are modeled as a symbolic set of paths under each root.
+func (t *shellTranslator) translateFind(command []string) {
+	// Skip the options that come before the starting points
+	args := skipOptions(command[1:], []string{"-D"})
+
+	// Collect the starting points which come before the expression
+	var roots []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") && !findOperators[args[0]] {
+		roots = append(roots, args[0])
+		args = args[1:]
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
 
-	return ffaList, nil
+	// Collect the actions performed on the matched paths
+	var deletes bool
+	var execs [][]string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-delete":
+			deletes = true
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(args) && args[end] != ";" && args[end] != "\\;" && args[end] != "+" {
+				end++
+			}
+			if end == len(args) {
+				t.warn("unterminated %s in find", args[i])
+			}
+			execs = append(execs, args[i+1:end])
+			i = end
+		default:
+			if findOperators[args[i]] {
+				t.warn("find expression '%s' cannot be represented, the matched paths are over-approximated", args[i])
+			}
+		}
+	}
+
+	for _, root := range roots {
+		// Each matched path is some unknown path under the root
+		prefix := strings.TrimSuffix(root, "/") + "/"
+		paths := t.newVar()
+		t.emit("%s = %s + INPUT;", paths, ffaString(prefix))
+
+		for _, exec := range execs {
+			wrapped := make([]string, len(exec))
+			for i, arg := range exec {
+				wrapped[i] = strings.ReplaceAll(arg, "{}", paths)
+			}
+			t.translateCommand(wrapped)
+		}
+		if deletes {
+			t.emit("rmr %s;", paths)
+		}
+
+		// Paths printed into a pipeline become the input of the next command
+		if !deletes && len(execs) == 0 && t.pipeDepth > 0 {
+			t.stdin = paths
+		}
+	}
+}
+
+// translateSed translates sed commands that edit files in place.
+func (t *shellTranslator) translateSed(command []string) {
+	var inPlace, hasScript bool
+	var operands []string
+	for i := 1; i < len(command); i++ {
+		arg := command[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, command[i+1:]...)
+			i = len(command)
+		case arg == "--expression" || arg == "--file" || arg == "--line-length":
+			hasScript = hasScript || arg != "--line-length"
+			i++
+		case strings.HasPrefix(arg, "--expression=") || strings.HasPrefix(arg, "--file="):
+			hasScript = true
+		case strings.HasPrefix(arg, "--in-place"):
+			inPlace = true
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Scan clustered short options (e.g. -ri, -ne)
+		cluster:
+			for j, c := range arg[1:] {
+				switch c {
+				case 'i':
+					// The rest of the cluster is the backup suffix
+					inPlace = true
+					break cluster
+				case 'e', 'f', 'l':
+					hasScript = hasScript || c != 'l'
+					if j+2 == len(arg) {
+						// The value is the next argument
+						i++
+					}
+					break cluster
+				}
+			}
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	// Without -e or -f the first operand is the script
+	if !hasScript && len(operands) > 0 {
+		operands = operands[1:]
+	}
+	if inPlace {
+		for _, filename := range operands {
+			t.emit("assert(exists %s);", ffaString(filename))
+		}
+	}
+}
+
+// translatePerl translates perl programs and one-liners that edit files in place.
+func (t *shellTranslator) translatePerl(command []string) {
+	var inPlace, hasScript bool
+	var operands []string
+	for i := 1; i < len(command); i++ {
+		arg := command[i]
+		switch {
+		case arg == "--":
+			operands = append(operands, command[i+1:]...)
+			i = len(command)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Scan clustered switches (e.g. -pi, -pi.bak, -pe)
+		cluster:
+			for j, c := range arg[1:] {
+				switch c {
+				case 'i':
+					// The rest of the cluster is the backup extension
+					inPlace = true
+					break cluster
+				case 'e', 'E', 'I', 'M', 'm':
+					hasScript = hasScript || c == 'e' || c == 'E'
+					if j+2 == len(arg) {
+						// The value is the next argument
+						i++
+					}
+					break cluster
+				}
+			}
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	// Without -e the first operand is the program file
+	if !hasScript && len(operands) > 0 {
+		t.emit("assert(exists %s);", ffaString(operands[0]))
+		operands = operands[1:]
+	}
+	if inPlace {
+		for _, filename := range operands {
+			t.emit("assert(exists %s);", ffaString(filename))
+		}
+	}
+}
+
+// installOptions lists the options of install that take an argument.
+var installOptions = []string{"-m", "--mode", "-o", "--owner", "-g", "--group", "-S", "--suffix",
+	"-t", "--target-directory", "--strip-program"}
+
+// translateInstall translates install commands which copy files and create
+// directories.
+func (t *shellTranslator) translateInstall(command []string) {
+	operands := skipOptions(command[1:], installOptions)
+	options := command[1 : len(command)-len(operands)]
+
+	// install -d creates each operand as a directory
+	if _, ok := extractFlagPrefix(options, "--directory"); ok || hasShortFlag(options, 'd', installOptions...) {
+		for _, dir := range operands {
+			t.emit("mkdir %s;", ffaString(dir))
+		}
+		return
+	}
+	createParents := hasShortFlag(options, 'D', installOptions...)
+
+	// Find the target directory if one was given
+	targetDir, ok := extractFlagPrefix(options, "--target-directory")
+	if value, found := shortFlagValue(options, 't', installOptions); found {
+		targetDir, ok = value, true
+	}
+	if !ok {
+		if len(operands) < 2 {
+			return
+		}
+		if len(operands) == 2 {
+			src, dst := operands[0], operands[1]
+			if dir := path.Dir(dst); createParents && dir != "." && dir != "/" {
+				t.emit("mkdir %s;", ffaString(dir))
+			}
+			t.emit("cp %s %s;", ffaString(src), ffaString(dst))
+			return
+		}
+		// The last operand is the target directory
+		targetDir = operands[len(operands)-1]
+		operands = operands[:len(operands)-1]
+	}
+
+	if createParents {
+		t.emit("mkdir %s;", ffaString(targetDir))
+	}
+	for _, src := range operands {
+		t.emit("cp %s %s;", ffaString(src), ffaString(path.Join(targetDir, path.Base(src))))
+	}
+}
+
+// translateMktemp translates mktemp commands. The created path is unknown so
+// it is bound to ffaVar as a symbolic path inside the temporary directory.
+func (t *shellTranslator) translateMktemp(command []string, ffaVar string) {
+	operands := skipOptions(command[1:], []string{"-p", "--suffix"})
+	options := command[1 : len(command)-len(operands)]
+
+	// Determine the directory the path will be created in
+	dir, inDir := extractFlagPrefix(options, "--tmpdir")
+	if _, flags := extractFlag(options, "-p", 1); len(flags) == 2 {
+		dir, inDir = flags[1], true
+	}
+	if hasShortFlag(options, 't') {
+		inDir = true
+	}
+	if dir == "" {
+		dir = "/tmp"
+	}
+
+	// The trailing X's of the template are replaced with random characters.
+	// A template is relative to the working directory unless a directory was
+	// requested, without a template the temporary directory is used.
+	prefix := path.Join(dir, "tmp.")
+	if len(operands) > 0 {
+		prefix = strings.TrimRight(operands[0], "X")
+		if inDir {
+			prefix = path.Join(dir, prefix)
+		}
+	}
+	t.emit("%s = %s + INPUT;", ffaVar, ffaString(prefix))
+
+	// -u only prints the name without creating anything
+	if hasShortFlag(options, 'u') {
+		return
+	}
+	if _, ok := extractFlagPrefix(options, "--directory"); ok || hasShortFlag(options, 'd') {
+		t.emit("mkdir %s;", ffaVar)
+	} else {
+		t.emit("touch %s;", ffaVar)
+	}
+}
+
+// gitOptions lists the global options of git that take an argument.
+var gitOptions = []string{"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--config-env", "--exec-path"}
+
+// gitCommandOptions lists the options that take an argument for each git
+// subcommand that is translated.
+var gitCommandOptions = map[string][]string{
+	"clone": {"-o", "--origin", "-b", "--branch", "-u", "--upload-pack", "--reference", "--reference-if-able",
+		"--separate-git-dir", "--depth", "--shallow-since", "--shallow-exclude", "-j", "--jobs", "--template",
+		"-c", "--config", "--filter", "--server-option"},
+	"init":      {"--template", "--separate-git-dir", "-b", "--initial-branch", "--object-format", "--shared"},
+	"rm":        {"--pathspec-from-file"},
+	"mv":        {},
+	"submodule": {"--depth", "-j", "--jobs", "--reference", "-b", "--branch", "--name"},
+}
+
+// translateGit translates git commands that change the working tree.
+func (t *shellTranslator) translateGit(command []string) {
+	args := skipOptions(command[1:], gitOptions)
+	if len(args) == 0 {
+		return
+	}
+
+	// Each -C changes the directory the command is run in
+	dir := ""
+	options := command[1 : len(command)-len(args)]
+	for i := 0; i < len(options); i++ {
+		if options[i] == "-C" && i+1 < len(options) {
+			i++
+			if path.IsAbs(options[i]) || dir == "" {
+				dir = options[i]
+			} else {
+				dir = path.Join(dir, options[i])
+			}
+		}
+	}
+	// in resolves a path relative to the directory given by -C
+	in := func(p string) string {
+		if dir == "" || path.IsAbs(p) {
+			return p
+		}
+		return path.Join(dir, p)
+	}
+
+	subcommand := args[0]
+	operands := skipOptions(args[1:], gitCommandOptions[subcommand])
+	subOptions := args[1 : len(args)-len(operands)]
+	switch subcommand {
+	case "clone":
+		if len(operands) == 0 {
+			break
+		}
+		dest := gitHumanishName(operands[0])
+		if len(operands) >= 2 {
+			dest = operands[1]
+		}
+		t.emit("mkdir %s;", ffaString(in(dest)))
+	case "init":
+		repoDir := "."
+		if len(operands) > 0 {
+			repoDir = operands[0]
+			t.emit("mkdir %s;", ffaString(in(repoDir)))
+		}
+		if _, bare := extractFlagPrefix(subOptions, "--bare"); !bare {
+			t.emit("mkdir %s;", ffaString(in(path.Join(repoDir, ".git"))))
+		}
+	case "rm":
+		// Only the index is changed for cached and dry runs
+		_, cached := extractFlagPrefix(subOptions, "--cached")
+		_, dryRun := extractFlagPrefix(subOptions, "--dry-run")
+		if cached || dryRun || hasShortFlag(subOptions, 'n') {
+			break
+		}
+		for _, p := range operands {
+			t.emit("rmr %s;", ffaString(in(p)))
+		}
+	case "mv":
+		if len(operands) < 2 {
+			break
+		}
+		dst := operands[len(operands)-1]
+		srcs := operands[:len(operands)-1]
+		for _, src := range srcs {
+			target := dst
+			if len(srcs) > 1 {
+				target = path.Join(dst, path.Base(src))
+			}
+			t.emit("cp %s %s;", ffaString(in(src)), ffaString(in(target)))
+			t.emit("rmr %s;", ffaString(in(src)))
+		}
+	case "checkout":
+		// Paths after -- are restored from the index
+		for i, arg := range args {
+			if arg == "--" {
+				for _, p := range args[i+1:] {
+					t.emit("touch %s;", ffaString(in(p)))
+				}
+				break
+			}
+		}
+	case "submodule":
+		if len(operands) == 0 || operands[0] != "update" {
+			break
+		}
+		paths := skipOptions(operands[1:], gitCommandOptions[subcommand])
+		for _, p := range paths {
+			t.emit("mkdir %s;", ffaString(in(p)))
+		}
+		if len(paths) == 0 {
+			// The submodule paths are defined in .gitmodules
+			submodule := t.newVar()
+			t.emit("%s = %s + INPUT;", submodule, ffaString(strings.TrimSuffix(in("."), "/")+"/"))
+			t.emit("mkdir %s;", submodule)
+		}
+	default:
+		// Other subcommands require the repository to exist
+		if dir != "" {
+			t.emit("assert(exists %s);", ffaString(dir))
+		}
+	}
+}
+
+// gitHumanishName returns the directory name git clone uses for a repository
+// URL when no destination is given (e.g. "repo" for "git@host:owner/repo.git").
+func gitHumanishName(url string) string {
+	name := strings.TrimSuffix(url, "/")
+	name = strings.TrimSuffix(name, "/.git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	return strings.TrimSuffix(name, ".bundle")
 }
@@ -1,6 +1,7 @@
 package ffa
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -153,6 +154,317 @@ touch after
 	}
 }
 
+func TestShellCommandWrappers(t *testing.T) {
+	script := getFFAScript(t, `
+sudo -u root mkdir -p /opt/app
+env FOO=1 BAR=2 touch /tmp/env
+nohup rm -rf /tmp/nohup
+nice -n 10 stdbuf -oL touch /tmp/nice
+command -v make
+exec ./run.sh
+`)
+	tokens := []string{"mkdir '/opt/app';", "touch '/tmp/env';", "rmr '/tmp/nohup';", "touch '/tmp/nice';",
+		"assert(exists './run.sh');"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "sudo") || strings.Contains(line, "make") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+func TestShellXargs(t *testing.T) {
+	script := getFFAScript(t, "cat files.txt | xargs -n 1 rm -f")
+	tokens := []string{"$x0 = INPUT;", "rmr $x0;"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	script = getFFAScript(t, "ls | xargs -I % cp % /backup/%")
+	tokens = []string{"$x0 = INPUT;", "cp $x0 '/backup/' + $x0;"}
+	tokenCount = verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	// Attached replacement strings replace every occurrence and add no path
+	for _, sh := range []string{"ls | xargs -I{} touch {}", "ls | xargs -n 1 -i touch {}", "ls | xargs -i{} touch {}"} {
+		script = getFFAScript(t, sh)
+		tokens = []string{"assert(! exists 'ls');", "$x0 = INPUT;", "touch $x0;"}
+		if !reflect.DeepEqual(script, tokens) {
+			t.Errorf("%s: expected %q, got %q", sh, tokens, script)
+		}
+	}
+}
+
+func TestShellFind(t *testing.T) {
+	script := getFFAScript(t, "find / -name '*.pyc' -delete")
+	tokens := []string{"$x0 = '/' + INPUT;", "rmr $x0;"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	script = getFFAScript(t, `find . -type f -exec chmod +x {} \;`)
+	tokens = []string{"$x0 = './' + INPUT;", "assert(exists $x0);"}
+	tokenCount = verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	script = getFFAScript(t, "find /tmp | xargs rm")
+	tokens = []string{"$x0 = '/tmp/' + INPUT;", "rmr $x0;"}
+	tokenCount = verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	script = getFFAScript(t, "find . -name a -o -name b -delete")
+	tokens = []string{"// warning:", "rmr $x0;"}
+	tokenCount = verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestShellInPlaceEditors(t *testing.T) {
+	script := getFFAScript(t, `
+sed -i 's/a/b/' /etc/a.conf
+sed -e 's/a/b/' -i.bak /etc/b.conf /etc/c.conf
+sed 's/a/b/' /etc/ignored.conf
+perl -pi -e 's/a/b/' /etc/d.conf
+`)
+	tokens := []string{"assert(exists '/etc/a.conf');", "assert(exists '/etc/b.conf');",
+		"assert(exists '/etc/c.conf');", "assert(exists '/etc/d.conf');"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	if len(script) != len(tokens) {
+		t.Errorf("expected %d statements, got %d", len(tokens), len(script))
+	}
+}
+
+func TestShellOwnership(t *testing.T) {
+	script := getFFAScript(t, `
+chown -R app:app /app /data
+chgrp --reference=/etc/passwd /etc/shadow
+`)
+	tokens := []string{"assert(exists '/app');", "assert(exists '/data');", "assert(exists '/etc/shadow');"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestShellInstall(t *testing.T) {
+	script := getFFAScript(t, `
+install -D -m 755 build/app /usr/local/bin/app
+install -d /var/lib/app /var/log/app
+install -m 644 a.conf b.conf /etc/app
+`)
+	tokens := []string{"mkdir '/usr/local/bin';", "cp 'build/app' '/usr/local/bin/app';",
+		"mkdir '/var/lib/app';", "mkdir '/var/log/app';",
+		"cp 'a.conf' '/etc/app/a.conf';", "cp 'b.conf' '/etc/app/b.conf';"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestShellInstallClusters(t *testing.T) {
+	tests := []struct {
+		script string
+		tokens []string
+	}{
+		{"install -Dm 755 app /usr/local/bin/app", []string{"mkdir '/usr/local/bin';", "cp 'app' '/usr/local/bin/app';"}},
+		{"install -m755 app /usr/local/bin/app", []string{"cp 'app' '/usr/local/bin/app';"}},
+		{"install -Dt /opt/bin a b", []string{"mkdir '/opt/bin';", "cp 'a' '/opt/bin/a';", "cp 'b' '/opt/bin/b';"}},
+		{"install -tdir a", []string{"cp 'a' 'dir/a';"}},
+		{"install -o root -Dm644 app.conf /etc/app/app.conf", []string{"mkdir '/etc/app';", "cp 'app.conf' '/etc/app/app.conf';"}},
+		{"install -gdocker -m 0644 app.conf /etc/app.conf", []string{"cp 'app.conf' '/etc/app.conf';"}},
+	}
+	for _, test := range tests {
+		script := getFFAScript(t, test.script)
+		tokenCount := verifyTokens(test.tokens, script)
+		if tokenCount != len(test.tokens) {
+			t.Errorf("%s: token '%s' not found in %q", test.script, test.tokens[tokenCount], script)
+		}
+		for _, line := range script {
+			if strings.Contains(line, "755") || strings.Contains(line, "644") || strings.HasPrefix(line, "mkdir 'app") {
+				t.Errorf("%s: unexpected statement %s", test.script, line)
+			}
+		}
+	}
+}
+
+func TestShellTruncateDd(t *testing.T) {
+	script := getFFAScript(t, `
+truncate -s 0 /var/log/app.log
+dd if=/dev/zero of=/swapfile bs=1M count=10
+`)
+	tokens := []string{"touch '/var/log/app.log';", "assert(exists '/dev/zero');", "touch '/swapfile';"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+
+	// Flags before the size do not take a value
+	script = getFFAScript(t, "truncate -o -s 10 /f")
+	if !reflect.DeepEqual(script, []string{"touch '/f';"}) {
+		t.Errorf("unexpected script %q", script)
+	}
+}
+
+func TestShellMktemp(t *testing.T) {
+	script := getFFAScript(t, `
+TMP=$(mktemp -d)
+mktemp -p /var/tmp build.XXXX
+`)
+	tokens := []string{"$x0 = '/tmp/tmp.' + INPUT;", "mkdir $x0;", "$x1 = '/var/tmp/build.' + INPUT;", "touch $x1;"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "exists 'mktemp'") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+func TestShellGit(t *testing.T) {
+	script := getFFAScript(t, `
+git clone https://github.com/rodneyxr/repo.git
+git clone --depth 1 -b master https://github.com/rodneyxr/other /opt/other
+git -C /opt/other pull
+git rm -r --cached vendor
+git rm -r docs
+git mv a.txt b.txt
+git checkout -- go.sum
+git init
+git submodule update --init --recursive
+`)
+	tokens := []string{"mkdir 'repo';", "mkdir '/opt/other';", "assert(exists '/opt/other');", "rmr 'docs';",
+		"cp 'a.txt' 'b.txt';", "rmr 'a.txt';", "touch 'go.sum';", "mkdir '.git';",
+		"$x0 = './' + INPUT;", "mkdir $x0;"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+	for _, line := range script {
+		if strings.Contains(line, "vendor") {
+			t.Errorf("unexpected statement %s", line)
+		}
+	}
+}
+
+func TestGitHumanishName(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://github.com/rodneyxr/repo":      "repo",
+		"https://github.com/rodneyxr/repo.git/": "repo",
+		"git@github.com:rodneyxr/repo.git":      "repo",
+		"/srv/git/repo/.git":                    "repo",
+	} {
+		if got := gitHumanishName(url); got != want {
+			t.Errorf("gitHumanishName(%s) = %s, want %s", url, got, want)
+		}
+	}
+}
+
+func TestShellInputVariables(t *testing.T) {
+	script := getFFAScript(t, `
+read -r -p "Install directory: " DIR
+mkdir "$DIR/bin"
+cp config.yaml $1
+OUT=/tmp/out
+while getopts "o:" opt; do
+	OUT=$OPTARG
+done
+touch "$OUT/log"
+`)
+	tokens := []string{"$x0 = INPUT;", "mkdir $x0 + '/bin';", "$x1 = INPUT;", "cp 'config.yaml' $x1;",
+		"$x2 = '/tmp/out';", "while", "$x3 = INPUT;", "$x4 = INPUT;", "$x2 = $x4;", "}", "touch $x2 + '/log';"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestShellForItems(t *testing.T) {
+	script := getFFAScript(t, `
+for f in a b; do touch $f; done
+for d in /opt/$1; do mkdir "$d"; done
+for p in *.txt; do rm $p; done
+mkdir $HOME/bin
+`)
+	tokens := []string{
+		"while (other) {", "if (other) {", "$x0 = 'a';", "} else {", "$x0 = 'b';", "}", "touch $x0;", "}",
+		"$x1 = INPUT;", "while (other) {", "$x2 = '/opt/' + $x1;", "mkdir $x2;", "}",
+		"$x3 = INPUT;", "while (other) {", "$x4 = $x3;", "rmr $x4;", "}",
+		"mkdir '$HOME/bin';",
+	}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found in %q", tokens[tokenCount], script)
+	}
+
+	// Only the positional parameter and the glob are bound to INPUT
+	inputs := 0
+	for _, line := range script {
+		if strings.HasSuffix(line, "= INPUT;") {
+			inputs++
+		}
+	}
+	if inputs != 2 {
+		t.Errorf("expected 2 INPUT bindings, got %d in %q", inputs, script)
+	}
+}
+
+func TestShellParamOperators(t *testing.T) {
+	tests := []struct {
+		script string
+		tokens []string
+	}{
+		{"mkdir ${Y:-/def}", []string{"mkdir '/def';"}},
+		{"Y=/y; mkdir ${Y-/def}", []string{"$x0 = '/y';", "mkdir $x0;"}},
+		{"mkdir ${Y:=/def}; touch $Y/a", []string{"$x0 = '/def';", "mkdir $x0;", "touch $x0 + '/a';"}},
+		{"Y=/y; mkdir ${Y=/def}", []string{"$x0 = '/y';", "mkdir $x0;"}},
+		{"Y=/y; mkdir /a${Y:+/alt}", []string{"$x0 = '/y';", "mkdir '/a/alt';"}},
+		{"mkdir /a${Y+/alt}", []string{"mkdir '/a';"}},
+	}
+	for _, test := range tests {
+		script := getFFAScript(t, test.script)
+		if !reflect.DeepEqual(script, test.tokens) {
+			t.Errorf("%s: expected %q, got %q", test.script, test.tokens, script)
+		}
+	}
+}
+
+func TestShellQuotedArguments(t *testing.T) {
+	script := getFFAScript(t, `touch 'a b' "c"`)
+	tokens := []string{"touch 'a b';", "touch 'c';"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found", tokens[tokenCount])
+	}
+}
+
+func TestShellLiteralVariableNames(t *testing.T) {
+	script := getFFAScript(t, `
+X=/a
+touch '$x5' "$X"'/$x0'
+`)
+	tokens := []string{"$x0 = '/a';", "touch '$x5';", "touch $x0 + '/$x0';"}
+	tokenCount := verifyTokens(tokens, script)
+	if tokenCount != len(tokens) {
+		t.Errorf("token '%s' not found in %q", tokens[tokenCount], script)
+	}
+}
+
 // verifyTokens ensures that all tokens are found in the script provided.
 // Returns the number of tokens found. If the return value equals the length of
 // the tokens array, then all tokens were found.